@@ -2,6 +2,7 @@ package benchmarks
 
 import (
 	"net/url"
+	"strconv"
 	"testing"
 
 	"github.com/go-playground/form"
@@ -651,3 +652,80 @@ func BenchmarkEncodeNestedStructParallel(b *testing.B) {
 		}
 	})
 }
+
+// Scaling Benchmarks
+
+type ManyKeysStruct struct {
+	Keys map[string]map[string]string
+}
+
+// getManyKeysValues returns n bracketed keys, each with its own alias eg. "Keys[k1]".
+func getManyKeysValues(n int) url.Values {
+
+	values := make(url.Values, n)
+
+	for i := 0; i < n; i++ {
+		values["Keys[k"+strconv.Itoa(i)+"][v]"] = []string{"value"}
+	}
+
+	return values
+}
+
+// BenchmarkDecodeManyKeys reports the time per key, which should stay
+// roughly the same as the number of keys grows.
+func BenchmarkDecodeManyKeys(b *testing.B) {
+
+	for _, n := range []int{5000, 10000, 25000, 50000} {
+
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+
+			values := getManyKeysValues(n)
+			decoder := form.NewDecoder()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var test ManyKeysStruct
+				if err := decoder.Decode(&test, values); err != nil {
+					b.Error(err)
+				}
+			}
+
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/key")
+		})
+	}
+}
+
+type ManyDynamicStruct struct {
+	Items []interface{}
+}
+
+// BenchmarkDecodeManyDynamic reports the time per key decoding into a slice of
+// dynamic values, which should stay roughly the same as the number of keys grows.
+func BenchmarkDecodeManyDynamic(b *testing.B) {
+
+	for _, n := range []int{1000, 4000, 8000} {
+
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+
+			values := make(url.Values, n)
+
+			for i := 0; i < n; i++ {
+				values["Items["+strconv.Itoa(i)+"].Name"] = []string{"value"}
+			}
+
+			decoder := form.NewDecoder()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var test ManyDynamicStruct
+				if err := decoder.Decode(&test, values); err != nil {
+					b.Error(err)
+				}
+			}
+
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/key")
+		})
+	}
+}
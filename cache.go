@@ -1,67 +1,166 @@
 package form
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
 
 type cachedField struct {
-	idx  int
-	name string
+	idx       int
+	name      string
+	aliases   []string
+	omitEmpty bool
+	max       int
+	min       int
+	style     SliceStyle
+	delim     string
+	trim      bool
+	field     reflect.StructField
+	options   []string
+	scalar    *scalarPlan
+	promoted  bool // an embedded struct whose hooks are promoted to, and so only called on, its parent
 }
 
 type cachedStruct struct {
-	fields []cachedField
+	fields       []cachedField
+	afterDecode  bool
+	validate     bool
+	beforeEncode bool
+	genDecode    bool
+	genEncode    bool
 }
 
-type structCacheMap struct {
-	m    atomic.Value // map[reflect.Type]*cachedStruct
-	lock sync.Mutex
+// cacheEntry is the entry of a single type, which is parsed only once no
+// matter how many goroutines ask for it at the same time.
+type cacheEntry struct {
+	once sync.Once
+	cs   atomic.Pointer[cachedStruct]
 }
 
-func newStructCacheMap() *structCacheMap {
-	sc := new(structCacheMap)
-	sc.m.Store(map[reflect.Type]*cachedStruct{})
+type structCacheMap struct {
+	m       sync.Map // map[reflect.Type]*cacheEntry
+	limit   int      // max unnamed struct types cached, 0 being unlimited
+	lock    sync.Mutex
+	dynamic []reflect.Type // unnamed struct types cached, oldest first; only kept when limited
+}
 
-	return sc
+func newStructCacheMap(limit int) *structCacheMap {
+	return &structCacheMap{limit: limit}
 }
 
 func (s *structCacheMap) Get(key reflect.Type) (value *cachedStruct, ok bool) {
-	value, ok = s.m.Load().(map[reflect.Type]*cachedStruct)[key]
-	return
+
+	e, ok := s.m.Load(key)
+	if !ok {
+		return nil, false
+	}
+
+	value = e.(*cacheEntry).cs.Load()
+
+	return value, value != nil
 }
 
-func (s *structCacheMap) Set(key reflect.Type, value *cachedStruct) {
+// parseStruct parses and caches the fields of struct type key; custom reports
+// whether a type has a custom type func, which is resolved once here so that
+// the field plans don't need to look it up on every call.
+//
+// Different types are parsed in parallel while callers asking for a type
+// already being parsed wait for, and share, its result.
+func (s *structCacheMap) parseStruct(key reflect.Type, tagNames []string, naming NamingFunc, fold CaseFolding, custom func(reflect.Type) bool) *cachedStruct {
+
+	v, ok := s.m.Load(key)
+	if !ok {
+		v, _ = s.m.LoadOrStore(key, new(cacheEntry))
+	}
+
+	e := v.(*cacheEntry)
+
+	e.once.Do(func() {
+
+		// a panic eg. from an invalid tag option, leaves the type uncached so that it panics for every caller
+		defer func() {
+			if e.cs.Load() == nil {
+				s.m.CompareAndDelete(key, e)
+			}
+		}()
 
-	m := s.m.Load().(map[reflect.Type]*cachedStruct)
+		e.cs.Store(newCachedStruct(key, tagNames, naming, fold, custom))
 
-	nm := make(map[reflect.Type]*cachedStruct, len(m)+1)
-	for k, v := range m {
-		nm[k] = v
+		if s.limit > 0 && key.Name() == blank {
+			s.evict(key)
+		}
+	})
+
+	if cs := e.cs.Load(); cs != nil {
+		return cs
 	}
-	nm[key] = value
-	s.m.Store(nm)
+
+	// the parse of the waited for entry panicked
+	return s.parseStruct(key, tagNames, naming, fold, custom)
 }
 
-func (s *structCacheMap) parseStruct(key reflect.Type, tagName string) *cachedStruct {
+// evict records the newly cached unnamed struct type key, unless already recorded,
+// and removes the oldest unnamed types over the limit, these including any built
+// at runtime eg. with reflect.StructOf; named types are never evicted as there's
+// a fixed number of them.
+func (s *structCacheMap) evict(key reflect.Type) {
 
 	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	// could have been multiple trying to access, but once first is done this ensures struct
-	// isn't parsed again.
-	cs, ok := s.Get(key)
-	if ok {
-		s.lock.Unlock()
-		return cs
+	for _, typ := range s.dynamic {
+		if typ == key {
+			return
+		}
 	}
 
-	cs = &cachedStruct{fields: make([]cachedField, 0, 4)} // init 4, betting most structs decoding into have at aleast 4 fields.
+	s.dynamic = append(s.dynamic, key)
+
+	for len(s.dynamic) > s.limit {
+		s.m.Delete(s.dynamic[0])
+		s.dynamic[0] = nil
+		s.dynamic = s.dynamic[1:]
+	}
+}
+
+// warmup parses the struct type typ, or that it points to or contains, along
+// with the struct types of its fields; seen holds the types already visited.
+func warmup(typ reflect.Type, seen map[reflect.Type]bool, parse func(reflect.Type) *cachedStruct) {
+
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct || typ == timeType || seen[typ] {
+		return
+	}
+
+	seen[typ] = true
+
+	cs := parse(typ)
+
+	for i := range cs.fields {
+		warmup(cs.fields[i].field.Type, seen, parse)
+	}
+}
+
+// newCachedStruct parses the fields, options and hooks of struct type key.
+func newCachedStruct(key reflect.Type, tagNames []string, naming NamingFunc, fold CaseFolding, custom func(reflect.Type) bool) *cachedStruct {
+
+	cs := &cachedStruct{fields: make([]cachedField, 0, 4)} // init 4, betting most structs decoding into have at aleast 4 fields.
 
 	numFields := key.NumField()
 
 	var fld reflect.StructField
 	var name string
+	var tag string
+	var opts string
+	var aliases []string
+	var f cachedField
 
 	for i := 0; i < numFields; i++ {
 
@@ -71,20 +170,183 @@ func (s *structCacheMap) parseStruct(key reflect.Type, tagName string) *cachedSt
 			continue
 		}
 
-		if name = fld.Tag.Get(tagName); name == ignore {
+		if tag = lookupTag(fld.Tag, tagNames); tag == ignore {
 			continue
 		}
 
+		name, opts = splitTag(tag)
+		name, aliases = splitAliases(name)
+
 		if len(name) == 0 {
-			name = fld.Name
+			if name = fld.Name; naming != nil {
+				name = naming(name)
+			}
 		}
 
-		cs.fields = append(cs.fields, cachedField{idx: i, name: name})
+		if fold != FoldNone {
+			name = foldName(name, fold)
+
+			for j := range aliases {
+				aliases[j] = foldName(aliases[j], fold)
+			}
+		}
+
+		f = cachedField{idx: i, name: name, aliases: aliases, field: fld}
+		f.parseOptions(fld.Name, opts)
+		f.scalar = planScalar(fld.Type, custom(fld.Type))
+
+		cs.fields = append(cs.fields, f)
 	}
 
-	s.Set(key, cs)
+	// hooks are looked up on the pointer so both value and pointer receivers are found
+	ptr := reflect.PtrTo(key)
+	cs.afterDecode = ptr.Implements(afterDecoderType)
+	cs.validate = ptr.Implements(validatorType)
+	cs.beforeEncode = ptr.Implements(beforeEncoderType)
+	cs.genDecode = ptr.Implements(formDecoderType)
+	cs.genEncode = ptr.Implements(formEncoderType)
+
+	for i := range cs.fields {
 
-	s.lock.Unlock()
+		if cs.fields[i].field.Anonymous {
+			cs.fields[i].promoted = promotesHooks(cs.fields[i].field.Type, ptr)
+		}
+	}
 
 	return cs
 }
+
+// promotesHooks reports whether every hook implemented by the embedded struct type typ,
+// or that it points to, is also implemented by ptr, a pointer to the embedding struct.
+func promotesHooks(typ reflect.Type, ptr reflect.Type) bool {
+
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+
+	embedded := reflect.PtrTo(typ)
+
+	for _, hook := range [...]reflect.Type{afterDecoderType, validatorType, beforeEncoderType} {
+
+		if embedded.Implements(hook) && !ptr.Implements(hook) {
+			return false
+		}
+	}
+
+	return true
+}
+
+var sliceStyles = map[string]SliceStyle{
+	"repeated":  SliceRepeated,
+	"indexed":   SliceIndexed,
+	"brackets":  SliceBrackets,
+	"delimited": SliceDelimited,
+}
+
+var namedDelimiters = map[string]string{
+	"comma":     ",",
+	"pipe":      "|",
+	"space":     " ",
+	"tab":       "\t",
+	"semicolon": ";",
+}
+
+// lookupTag returns the value of the first of tagNames present on the field,
+// so that eg. a `json` tag can be used when there is no `form` tag.
+func lookupTag(tag reflect.StructTag, tagNames []string) string {
+
+	for _, name := range tagNames {
+
+		if value, ok := tag.Lookup(name); ok {
+			return value
+		}
+	}
+
+	return blank
+}
+
+// splitTag splits a tag value, in the same format as encoding/json, into its
+// name and the comma separated options that follow it.
+func splitTag(tag string) (name string, opts string) {
+
+	if idx := strings.IndexByte(tag, ','); idx != -1 {
+		return tag[:idx], tag[idx+1:]
+	}
+
+	return tag, blank
+}
+
+// splitAliases splits a tag name of the form "primary|alias|alias"
+// into its primary name and any aliases.
+func splitAliases(name string) (string, []string) {
+
+	if strings.IndexByte(name, aliasSeparator) == -1 {
+		return name, nil
+	}
+
+	names := strings.Split(name, string(aliasSeparator))
+
+	return names[0], names[1:]
+}
+
+// parseOptions sets the options of the field from opts, as returned from splitTag;
+// unknown options are ignored so that tags shared with eg. encoding/json work.
+func (f *cachedField) parseOptions(fieldName string, opts string) {
+
+	var o, option, value string
+	var err error
+	var styled bool
+
+	for len(opts) > 0 {
+
+		o, opts, _ = strings.Cut(opts, ",")
+		option, value, _ = strings.Cut(o, "=")
+
+		switch option {
+		case "omitempty":
+			f.omitEmpty = true
+
+		case "trim":
+			f.trim = true
+
+		case "max":
+			if f.max, err = strconv.Atoi(value); err != nil || f.max < 1 {
+				panic(fmt.Sprintf(errTagOption, o, fieldName))
+			}
+
+		case "min":
+			if f.min, err = strconv.Atoi(value); err != nil || f.min < 0 {
+				panic(fmt.Sprintf(errTagOption, o, fieldName))
+			}
+
+		case "slice":
+			if f.style, styled = sliceStyles[value]; !styled {
+				panic(fmt.Sprintf(errTagOption, o, fieldName))
+			}
+
+		case "delim":
+
+			// a comma delimiter splits the options eg. `form:"ids,delim=,"`
+			if len(value) == 0 {
+				value, o = ",", "delim=,"
+				opts = strings.TrimPrefix(opts, ",")
+			}
+
+			if d, ok := namedDelimiters[value]; ok {
+				value = d
+			}
+
+			f.delim = value
+		}
+
+		f.options = append(f.options, o)
+	}
+
+	if len(f.delim) > 0 && !styled {
+		f.style = SliceDelimited
+	}
+}
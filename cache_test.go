@@ -1,8 +1,11 @@
 package form
 
 import (
+	"net/url"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	. "gopkg.in/go-playground/assert.v1"
 )
@@ -27,7 +30,7 @@ import (
 
 func TestDecoderMultipleSimultaniousParseStructRequests(t *testing.T) {
 
-	sc := newStructCacheMap()
+	sc := newStructCacheMap(0)
 
 	type Struct struct {
 		Array []int
@@ -43,10 +46,136 @@ func TestDecoderMultipleSimultaniousParseStructRequests(t *testing.T) {
 	for i := 0; i < 200; i++ {
 		go func() {
 			<-proceed
-			s := sc.parseStruct(typ, "form")
+			s := sc.parseStruct(typ, []string{"form"}, nil, FoldNone, func(reflect.Type) bool { return false })
 			NotEqual(t, s, nil)
 		}()
 	}
 
 	close(proceed)
 }
+
+func TestStructCacheDynamicTypeLimit(t *testing.T) {
+
+	type Named struct {
+		Value string
+	}
+
+	decoder := NewDecoder()
+	decoder.SetDynamicTypeLimit(2)
+
+	errs := decoder.Decode(&Named{}, url.Values{"Value": []string{"1"}})
+	Equal(t, errs, nil)
+
+	var types []reflect.Type
+
+	for i := 0; i < 5; i++ {
+
+		typ := reflect.StructOf([]reflect.StructField{{
+			Name: "Value",
+			Type: reflect.TypeOf(""),
+			Tag:  reflect.StructTag(`form:"v` + strconv.Itoa(i) + `"`),
+		}})
+
+		v := reflect.New(typ)
+
+		errs = decoder.Decode(v.Interface(), url.Values{"v" + strconv.Itoa(i): []string{"val"}})
+		Equal(t, errs, nil)
+		Equal(t, v.Elem().Field(0).String(), "val")
+
+		types = append(types, typ)
+	}
+
+	// only the last 2 unnamed types are kept, named types are never evicted
+	for i, typ := range types {
+		_, ok := decoder.structCache.Get(typ)
+		Equal(t, ok, i >= 3)
+	}
+
+	_, ok := decoder.structCache.Get(reflect.TypeOf(Named{}))
+	Equal(t, ok, true)
+
+	// an evicted type is parsed again
+	v := reflect.New(types[0])
+
+	errs = decoder.Decode(v.Interface(), url.Values{"v0": []string{"again"}})
+	Equal(t, errs, nil)
+	Equal(t, v.Elem().Field(0).String(), "again")
+
+	_, ok = decoder.structCache.Get(types[0])
+	Equal(t, ok, true)
+
+	_, ok = decoder.structCache.Get(types[3])
+	Equal(t, ok, false)
+
+	// unnamed types declared in the program count against the limit too
+	var inline struct {
+		Inline string
+	}
+
+	errs = decoder.Decode(&inline, url.Values{"Inline": []string{"1"}})
+	Equal(t, errs, nil)
+
+	for _, typ := range types {
+		errs = decoder.Decode(reflect.New(typ).Interface(), url.Values{})
+		Equal(t, errs, nil)
+	}
+
+	_, ok = decoder.structCache.Get(reflect.TypeOf(inline))
+	Equal(t, ok, false)
+	Equal(t, inline.Inline, "1")
+
+	// a type is only recorded once
+	cache := newStructCacheMap(2)
+	cache.evict(types[0])
+	cache.evict(types[0])
+	Equal(t, len(cache.dynamic), 1)
+}
+
+func TestStructCacheWarmup(t *testing.T) {
+
+	type Leaf struct {
+		Value string
+	}
+
+	type Node struct {
+		Leaves   []*Leaf
+		ByName   map[string]Leaf
+		Parent   *Node
+		Created  time.Time
+		Optional Optional[Leaf]
+	}
+
+	decoder := NewDecoder()
+	decoder.Warmup((*Node)(nil), nil, 5)
+
+	for _, v := range []interface{}{Node{}, Leaf{}, Optional[Leaf]{}} {
+		_, ok := decoder.structCache.Get(reflect.TypeOf(v))
+		Equal(t, ok, true)
+	}
+
+	_, ok := decoder.structCache.Get(reflect.TypeOf(time.Time{}))
+	Equal(t, ok, false)
+
+	encoder := NewEncoder()
+	encoder.Warmup([]Leaf{})
+
+	_, ok = encoder.structCache.Get(reflect.TypeOf(Leaf{}))
+	Equal(t, ok, true)
+}
+
+func TestStructCacheParsePanic(t *testing.T) {
+
+	type Bad struct {
+		Value []int `form:",max=x"`
+	}
+
+	decoder := NewDecoder()
+
+	// the type isn't cached, so every decode panics rather than using a partial result
+	for i := 0; i < 2; i++ {
+		PanicMatches(t, func() { decoder.Decode(&Bad{}, url.Values{}) }, "Invalid tag option 'max=x' on field 'Value'")
+	}
+
+	_, ok := decoder.structCache.Get(reflect.TypeOf(Bad{}))
+	Equal(t, ok, false)
+}
@@ -0,0 +1,35 @@
+// Package example holds types with methods generated by formgen, which are
+// tested against decoding and encoding the same types using reflection.
+package example
+
+import "time"
+
+//go:generate go run github.com/go-playground/form/cmd/formgen -type User,Address
+
+// Age is a defined integer type, decoded directly by the generated methods.
+type Age uint8
+
+// User is a struct with fields both decoded directly and using reflection.
+type User struct {
+	Name      string
+	Age       Age    `form:"age,omitempty"`
+	Email     string `form:"email|mail"`
+	Active    bool
+	Score     float64
+	Ratio     float32 `form:",omitempty"`
+	Count     int64
+	Small     int8
+	Tags      []string
+	Address   Address
+	Addresses []Address
+	Created   time.Time
+	Meta      map[string]int
+	Ignored   string `form:"-"`
+	private   string
+}
+
+// Address is nested within User.
+type Address struct {
+	Street string
+	Zip    int `form:"zip"`
+}
@@ -0,0 +1,225 @@
+package example
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/form"
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+// plainUser and plainAddress are copies of User and Address without the
+// generated methods, so are decoded and encoded using reflection.
+type plainUser struct {
+	Name      string
+	Age       Age    `form:"age,omitempty"`
+	Email     string `form:"email|mail"`
+	Active    bool
+	Score     float64
+	Ratio     float32 `form:",omitempty"`
+	Count     int64
+	Small     int8
+	Tags      []string
+	Address   plainAddress
+	Addresses []plainAddress
+	Created   time.Time
+	Meta      map[string]int
+	Ignored   string `form:"-"`
+	private   string
+}
+
+type plainAddress struct {
+	Street string
+	Zip    int `form:"zip"`
+}
+
+var _ form.FormDecoder = (*User)(nil)
+var _ form.FormEncoder = (*User)(nil)
+
+var testValues = url.Values{
+	"Name":                []string{"Joey"},
+	"age":                 []string{"3"},
+	"mail":                []string{"joey@example.com"},
+	"Active":              []string{"on"},
+	"Score":               []string{"1.5"},
+	"Ratio":               []string{"0.1"},
+	"Count":               []string{"-7"},
+	"Small":               []string{"300"},
+	"Tags":                []string{"a", "b"},
+	"Address.Street":      []string{"Here"},
+	"Address.zip":         []string{"abc"},
+	"Addresses[1].Street": []string{"There"},
+	"Addresses[1].zip":    []string{"2"},
+	"Created":             []string{"2016-01-02T00:00:00Z"},
+	"Meta[a]":             []string{"1"},
+	"Ignored":             []string{"x"},
+	"private":             []string{"x"},
+}
+
+// errorStrings returns the messages of err, a form.DecodeErrors or form.EncodeErrors.
+func errorStrings(err error) map[string]string {
+
+	m := make(map[string]string)
+
+	switch errs := err.(type) {
+	case form.DecodeErrors:
+		for k, e := range errs {
+			m[k] = e.Error()
+		}
+	case form.EncodeErrors:
+		for k, e := range errs {
+			m[k] = e.Error()
+		}
+	}
+
+	return m
+}
+
+func asJSON(t *testing.T, v interface{}) string {
+	b, err := json.Marshal(v)
+	Equal(t, err, nil)
+	return string(b)
+}
+
+func TestGeneratedMatchesReflection(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		decoder func() *form.Decoder
+		encoder func() *form.Encoder
+	}{
+		{
+			name:    "default",
+			decoder: func() *form.Decoder { return form.NewDecoder() },
+			encoder: func() *form.Encoder { return form.NewEncoder() },
+		},
+		{
+			name: "custom types",
+			decoder: func() *form.Decoder {
+				d := form.NewDecoder()
+				form.RegisterDecodeFunc(d, func(vals []string) (Age, error) {
+					if vals[0] == "old" {
+						return 99, nil
+					}
+					return 0, errors.New("invalid age")
+				})
+				form.RegisterDecodeFunc(d, func(vals []string) (time.Time, error) {
+					return time.Parse("2006-01-02", vals[0])
+				})
+				return d
+			},
+			encoder: func() *form.Encoder {
+				e := form.NewEncoder()
+				form.RegisterEncodeFunc(e, func(a Age) ([]string, error) {
+					return []string{strings.Repeat("I", int(a))}, nil
+				})
+				return e
+			},
+		},
+		{
+			name: "context funcs",
+			decoder: func() *form.Decoder {
+				d := form.NewDecoder()
+				d.RegisterMatchedContextTypeFunc(func(fc form.FieldContext, vals []string) (interface{}, error) {
+					return fc.Field.Name + ":" + strings.Join(fc.Options, ",") + ":" + vals[0], nil
+				}, form.MatchKind(reflect.String))
+				d.RegisterContextTypeFunc(func(fc form.FieldContext, vals []string) (interface{}, error) {
+					return Age(len(fc.Field.Name) + len(fc.Options)), nil
+				}, Age(0))
+				return d
+			},
+			encoder: func() *form.Encoder {
+				e := form.NewEncoder()
+				e.RegisterMatchedContextTypeFunc(func(fc form.FieldContext, x interface{}) ([]string, error) {
+					return []string{fc.Namespace + ":" + fc.Field.Name + ":" + strings.Join(fc.Options, ",") + ":" + reflect.ValueOf(x).String()}, nil
+				}, form.MatchKind(reflect.String))
+				return e
+			},
+		},
+		{
+			name: "naming",
+			decoder: func() *form.Decoder {
+				d := form.NewDecoder()
+				d.SetNamingFunc(form.LowerCase)
+				return d
+			},
+			encoder: func() *form.Encoder {
+				e := form.NewEncoder()
+				e.SetNamingFunc(form.LowerCase)
+				return e
+			},
+		},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			var generated User
+			var plain plainUser
+
+			decoder := tt.decoder()
+
+			genErr := decoder.Decode(&generated, testValues)
+			plainErr := decoder.Decode(&plain, testValues)
+
+			Equal(t, errorStrings(genErr), errorStrings(plainErr))
+			Equal(t, asJSON(t, generated), asJSON(t, plain))
+
+			encoder := tt.encoder()
+
+			genValues, genErr := encoder.Encode(&generated)
+			plainValues, plainErr := encoder.Encode(plain)
+
+			Equal(t, errorStrings(genErr), errorStrings(plainErr))
+			Equal(t, genValues, plainValues)
+		})
+	}
+}
+
+func BenchmarkDecodeGenerated(b *testing.B) {
+
+	decoder := form.NewDecoder()
+	values := url.Values{"Street": []string{"Here"}, "zip": []string{"12345"}}
+
+	for i := 0; i < b.N; i++ {
+		var a Address
+		_ = decoder.Decode(&a, values)
+	}
+}
+
+func BenchmarkDecodeReflection(b *testing.B) {
+
+	decoder := form.NewDecoder()
+	values := url.Values{"Street": []string{"Here"}, "zip": []string{"12345"}}
+
+	for i := 0; i < b.N; i++ {
+		var a plainAddress
+		_ = decoder.Decode(&a, values)
+	}
+}
+
+func BenchmarkEncodeGenerated(b *testing.B) {
+
+	encoder := form.NewEncoder()
+	a := Address{Street: "Here", Zip: 12345}
+
+	for i := 0; i < b.N; i++ {
+		_, _ = encoder.Encode(&a)
+	}
+}
+
+func BenchmarkEncodeReflection(b *testing.B) {
+
+	encoder := form.NewEncoder()
+	a := plainAddress{Street: "Here", Zip: 12345}
+
+	for i := 0; i < b.N; i++ {
+		_, _ = encoder.Encode(&a)
+	}
+}
@@ -0,0 +1,53 @@
+// Code generated by formgen; DO NOT EDIT.
+
+package example
+
+import "github.com/go-playground/form"
+
+// DecodeForm decodes the values under namespace ns into t, see form.FormDecoder.
+func (t *User) DecodeForm(g *form.GenDecoder, ns string) (set bool) {
+	set = form.DecodeString(g, ns, "Name", &t.Name) || set
+	set = form.DecodeUint(g, ns, "age", &t.Age) || set
+	set = form.DecodeStructField(g, ns, t, 2) || set
+	set = form.DecodeBool(g, ns, "Active", &t.Active) || set
+	set = form.DecodeFloat(g, ns, "Score", &t.Score) || set
+	set = form.DecodeFloat(g, ns, "Ratio", &t.Ratio) || set
+	set = form.DecodeInt(g, ns, "Count", &t.Count) || set
+	set = form.DecodeInt(g, ns, "Small", &t.Small) || set
+	set = form.DecodeStructField(g, ns, t, 8) || set
+	set = form.DecodeStructField(g, ns, t, 9) || set
+	set = form.DecodeStructField(g, ns, t, 10) || set
+	set = form.DecodeStructField(g, ns, t, 11) || set
+	set = form.DecodeStructField(g, ns, t, 12) || set
+	return
+}
+
+// EncodeForm encodes t under namespace ns, see form.FormEncoder.
+func (t *User) EncodeForm(g *form.GenEncoder, ns string) {
+	form.EncodeString(g, ns, "Name", t.Name, false)
+	form.EncodeUint(g, ns, "age", t.Age, true)
+	form.EncodeStructField(g, ns, t, 2)
+	form.EncodeBool(g, ns, "Active", t.Active, false)
+	form.EncodeFloat(g, ns, "Score", t.Score, false)
+	form.EncodeFloat(g, ns, "Ratio", t.Ratio, true)
+	form.EncodeInt(g, ns, "Count", t.Count, false)
+	form.EncodeInt(g, ns, "Small", t.Small, false)
+	form.EncodeStructField(g, ns, t, 8)
+	form.EncodeStructField(g, ns, t, 9)
+	form.EncodeStructField(g, ns, t, 10)
+	form.EncodeStructField(g, ns, t, 11)
+	form.EncodeStructField(g, ns, t, 12)
+}
+
+// DecodeForm decodes the values under namespace ns into t, see form.FormDecoder.
+func (t *Address) DecodeForm(g *form.GenDecoder, ns string) (set bool) {
+	set = form.DecodeString(g, ns, "Street", &t.Street) || set
+	set = form.DecodeInt(g, ns, "zip", &t.Zip) || set
+	return
+}
+
+// EncodeForm encodes t under namespace ns, see form.FormEncoder.
+func (t *Address) EncodeForm(g *form.GenEncoder, ns string) {
+	form.EncodeString(g, ns, "Street", t.Street, false)
+	form.EncodeInt(g, ns, "zip", t.Zip, false)
+}
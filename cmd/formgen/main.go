@@ -0,0 +1,292 @@
+// formgen generates DecodeForm and EncodeForm methods for struct types, which
+// the form Decoder and Encoder call instead of decoding and encoding them
+// using reflection.
+//
+// Usage:
+//
+//	//go:generate go run github.com/go-playground/form/cmd/formgen -type User,Address
+//
+// Fields of string, bool, integer and float types, including types defined
+// from them within the same package, whose form tag has no options other than
+// omitempty are decoded and encoded directly; all other fields eg. slices,
+// maps, structs or those with aliases fall back to reflection for that field.
+// Custom type funcs registered for a field's type are honoured either way.
+//
+// The generated methods follow the default tag and naming rules, they are not
+// used by a Decoder or Encoder configured otherwise eg. using SetTagName.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const formPkg = "github.com/go-playground/form"
+
+var (
+	typeNames = flag.String("type", "", "comma separated list of struct type names; required")
+	output    = flag.String("output", "", "output file name; default <dir>/<type>_form.go")
+)
+
+func main() {
+
+	log.SetFlags(0)
+	log.SetPrefix("formgen: ")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: formgen -type T[,T...] [-output file] [directory]\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if len(*typeNames) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+
+	if args := flag.Args(); len(args) > 0 {
+		dir = args[0]
+	}
+
+	types := strings.Split(*typeNames, ",")
+
+	name := *output
+	if len(name) == 0 {
+		name = filepath.Join(dir, strings.ToLower(types[0])+"_form.go")
+	}
+
+	src, err := generate(dir, types, filepath.Base(name))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = os.WriteFile(name, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// field is a struct field to generate code for.
+type field struct {
+	index     int
+	goName    string
+	name      string
+	helper    string // eg. "Int" for form.DecodeInt, blank to fall back to reflection
+	omitEmpty bool
+}
+
+type generator struct {
+	specs map[string]*ast.TypeSpec
+	buf   bytes.Buffer
+}
+
+// generate returns the source of the methods for types in the package in dir,
+// ignoring the previously generated file skip.
+func generate(dir string, types []string, skip string) ([]byte, error) {
+
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{specs: make(map[string]*ast.TypeSpec)}
+	fset := token.NewFileSet()
+
+	for _, name := range pkg.GoFiles {
+
+		if name == skip {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+
+			if spec, ok := n.(*ast.TypeSpec); ok {
+				g.specs[spec.Name.Name] = spec
+			}
+
+			return true
+		})
+	}
+
+	fmt.Fprintf(&g.buf, "// Code generated by formgen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&g.buf, "package %s\n\n", pkg.Name)
+	fmt.Fprintf(&g.buf, "import %q\n", formPkg)
+
+	for _, name := range types {
+
+		if err = g.generateType(strings.TrimSpace(name)); err != nil {
+			return nil, err
+		}
+	}
+
+	return format.Source(g.buf.Bytes())
+}
+
+func (g *generator) generateType(name string) error {
+
+	spec, ok := g.specs[name]
+	if !ok {
+		return fmt.Errorf("type %s not found", name)
+	}
+
+	if spec.TypeParams != nil {
+		return fmt.Errorf("type %s has type parameters which are not supported", name)
+	}
+
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return fmt.Errorf("type %s is not a struct", name)
+	}
+
+	fields, err := g.fields(st)
+	if err != nil {
+		return fmt.Errorf("type %s: %v", name, err)
+	}
+
+	fmt.Fprintf(&g.buf, "\n// DecodeForm decodes the values under namespace ns into t, see form.FormDecoder.\n")
+	fmt.Fprintf(&g.buf, "func (t *%s) DecodeForm(g *form.GenDecoder, ns string) (set bool) {\n", name)
+
+	for _, f := range fields {
+
+		if len(f.helper) == 0 {
+			fmt.Fprintf(&g.buf, "\tset = form.DecodeStructField(g, ns, t, %d) || set\n", f.index)
+			continue
+		}
+
+		fmt.Fprintf(&g.buf, "\tset = form.Decode%s(g, ns, %q, &t.%s) || set\n", f.helper, f.name, f.goName)
+	}
+
+	fmt.Fprintf(&g.buf, "\treturn\n}\n")
+
+	fmt.Fprintf(&g.buf, "\n// EncodeForm encodes t under namespace ns, see form.FormEncoder.\n")
+	fmt.Fprintf(&g.buf, "func (t *%s) EncodeForm(g *form.GenEncoder, ns string) {\n", name)
+
+	for _, f := range fields {
+
+		if len(f.helper) == 0 {
+			fmt.Fprintf(&g.buf, "\tform.EncodeStructField(g, ns, t, %d)\n", f.index)
+			continue
+		}
+
+		fmt.Fprintf(&g.buf, "\tform.Encode%s(g, ns, %q, t.%s, %t)\n", f.helper, f.name, f.goName, f.omitEmpty)
+	}
+
+	fmt.Fprintf(&g.buf, "}\n")
+
+	return nil
+}
+
+// fields returns the fields of st to generate code for, following the same
+// rules as the Decoder and Encoder.
+func (g *generator) fields(st *ast.StructType) ([]field, error) {
+
+	var fields []field
+	var idx int
+
+	for _, fl := range st.Fields.List {
+
+		var tag string
+
+		if fl.Tag != nil {
+
+			var err error
+
+			if tag, err = strconv.Unquote(fl.Tag.Value); err != nil {
+				return nil, err
+			}
+		}
+
+		tag = reflect.StructTag(tag).Get("form")
+
+		// embedded fields are always included
+		if len(fl.Names) == 0 {
+
+			if tag != "-" {
+				fields = append(fields, field{index: idx})
+			}
+
+			idx++
+			continue
+		}
+
+		for _, n := range fl.Names {
+
+			i := idx
+			idx++
+
+			if !n.IsExported() || tag == "-" {
+				continue
+			}
+
+			name, opts, _ := strings.Cut(tag, ",")
+			if len(name) == 0 {
+				name = n.Name
+			}
+
+			f := field{index: i, goName: n.Name, name: name}
+
+			if !strings.Contains(name, "|") && (len(opts) == 0 || opts == "omitempty") {
+				f.helper = g.helper(fl.Type, 0)
+				f.omitEmpty = opts == "omitempty"
+			}
+
+			fields = append(fields, f)
+		}
+	}
+
+	return fields, nil
+}
+
+// helper returns the name of the helper for fields of type expr eg. "Int",
+// or blank when the field must fall back to reflection.
+func (g *generator) helper(expr ast.Expr, depth int) string {
+
+	id, ok := expr.(*ast.Ident)
+	if !ok || depth > 8 {
+		return ""
+	}
+
+	// types defined within the package shadow the predeclared ones
+	if spec, ok := g.specs[id.Name]; ok {
+
+		if spec.TypeParams != nil {
+			return ""
+		}
+
+		return g.helper(spec.Type, depth+1)
+	}
+
+	switch id.Name {
+	case "string":
+		return "String"
+	case "bool":
+		return "Bool"
+	case "int", "int8", "int16", "int32", "int64", "rune":
+		return "Int"
+	case "uint", "uint8", "uint16", "uint32", "uint64", "byte":
+		return "Uint"
+	case "float32", "float64":
+		return "Float"
+	}
+
+	return ""
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestGenerate(t *testing.T) {
+
+	expected, err := os.ReadFile("example/user_form.go")
+	Equal(t, err, nil)
+
+	src, err := generate("example", []string{"User", "Address"}, "user_form.go")
+	Equal(t, err, nil)
+	Equal(t, string(src), string(expected))
+
+	_, err = generate("example", []string{"Missing"}, "user_form.go")
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "type Missing not found")
+
+	_, err = generate("example", []string{"Age"}, "user_form.go")
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "type Age is not a struct")
+}
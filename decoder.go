@@ -1,26 +1,59 @@
 package form
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
+	"unsafe"
 )
 
 const (
 	errArraySize           = "Array size of '%d' is larger than the maximum currently set on the decoder of '%d'. To increase this limit please see, SetMaxArraySize(size uint)"
 	errMissingStartBracket = "Invalid formatting for key '%s' missing '[' bracket"
 	errMissingEndBracket   = "Invalid formatting for key '%s' missing ']' bracket"
+	errMultipleAliases     = "Multiple aliases '%s' and '%s' present for a single field"
+	errArrayLength         = "Array size of '%d' is larger than the fixed array length of '%d'"
+	errFieldMax            = "Size of '%d' is larger than the maximum of '%d' set on the field"
+	errFieldMin            = "Size of '%d' is smaller than the minimum of '%d' set on the field"
+	errSparseArray         = "Array size of '%d' is too sparse for the '%d' elements provided. To change this policy please see, SetSparsePolicy(policy SparsePolicy, factor uint)"
+	errCustomTypeNil       = "Custom type func returned nil for type '%s' which can't be nil"
+	errCustomTypeMismatch  = "Custom type func returned type '%s' which can't be assigned to type '%s'"
+	errUnknownTypeName     = "Type name '%s' is not registered on the decoder"
+	errTypeNotAssignable   = "Type '%s' registered as '%s' can't be assigned to type '%s'"
 )
 
 type decoder struct {
-	d         *Decoder
-	errs      DecodeErrors
-	dm        dataMap
-	values    url.Values
-	maxKeyLen int
+	d          *Decoder
+	ctx        context.Context
+	field      *cachedField
+	plain      bool
+	scalar     bool
+	gen        GenDecoder
+	errs       DecodeErrors
+	dm         *dataMap
+	values     url.Values
+	maxKeyLen  int
+	mapEntries int
+	dynamic    *dynamicNode // tree of all keys, built on first decoding a dynamic value
+	mode       Mode
+	buf        []byte // namespace buffer, kept when the decoder is pooled
+}
+
+var decoderPool = sync.Pool{New: func() interface{} {
+	return &decoder{buf: make([]byte, 0, 64)}
+}}
+
+// release returns d to the pool once the values, errors and data it
+// references are no longer needed, keeping only its namespace buffer.
+func (d *decoder) release() {
+	*d = decoder{buf: d.buf[:0]}
+	decoderPool.Put(d)
 }
 
 func (d *decoder) setError(namespace []byte, err error) {
@@ -32,31 +65,23 @@ func (d *decoder) setError(namespace []byte, err error) {
 }
 
 func (d *decoder) findAlias(ns string) *recursiveData {
-
-	for i := 0; i < len(d.dm); i++ {
-
-		if d.dm[i].alias == ns {
-			return d.dm[i]
-		}
-	}
-
-	return nil
+	return d.dm.index[ns]
 }
 
 func (d *decoder) parseMapData() {
 
 	// already parsed
-	if len(d.dm) != 0 {
+	if d.dm != nil {
 		return
 	}
 
-	d.dm = d.d.dataPool.Get().(dataMap)[0:0]
+	d.dm = d.d.dataPool.Get().(*dataMap)
 	var i int
 	var idx int
-	var l int
 	var insideBracket bool
 	var rd *recursiveData
 	var isNum bool
+	var err error
 
 	for k := range d.values {
 
@@ -79,24 +104,7 @@ func (d *decoder) parseMapData() {
 				}
 
 				if rd = d.findAlias(k[:idx]); rd == nil {
-
-					l = len(d.dm) + 1
-
-					if l > cap(d.dm) {
-						dm := make(dataMap, l, l)
-						copy(dm, d.dm)
-						rd = new(recursiveData)
-						dm[len(d.dm)] = rd
-						d.dm = dm
-					} else {
-						l = len(d.dm)
-						d.dm = d.dm[:l+1]
-						rd = d.dm[l]
-						rd.sliceLen = 0
-						rd.keys = rd.keys[0:0]
-					}
-
-					rd.alias = k[:idx]
+					rd = d.dm.add(k[:idx])
 				}
 
 				// is map + key
@@ -109,10 +117,11 @@ func (d *decoder) parseMapData() {
 				// is key is number, most likely array key, keep track of just in case an array/slice.
 				if isNum {
 
-					// no need to check for error, it will always pass
-					// as we have done the checking to ensure
-					// the value is a number ahead of time.
-					ke.ivalue, _ = strconv.Atoi(ke.value)
+					// we have done the checking to ensure the value is a number
+					// ahead of time, so it can only fail when out of range.
+					if ke.ivalue, err = strconv.Atoi(ke.value); err != nil && len(ke.value) > 0 {
+						ke.ivalue = -1
+					}
 
 					if ke.ivalue > rd.sliceLen {
 						rd.sliceLen = ke.ivalue
@@ -123,6 +132,13 @@ func (d *decoder) parseMapData() {
 				rd.keys = append(rd.keys, ke)
 
 				insideBracket = false
+			case namespaceSeparator:
+
+				if !insideBracket {
+					d.dm.parents[k[:i]] = struct{}{}
+				} else {
+					isNum = false
+				}
 			default:
 				// checking if not a number, 0-9 is 48-57 in byte, see for yourself fmt.Println('0', '1', '2', '3', '4', '5', '6', '7', '8', '9')
 				if insideBracket && (k[i] > 57 || k[i] < 48) {
@@ -140,55 +156,422 @@ func (d *decoder) parseMapData() {
 
 func (d *decoder) traverseStruct(v reflect.Value, namespace []byte) (set bool) {
 	typ := v.Type()
-	l := len(namespace)
-	first := l == 0
+	first := len(namespace) == 0
+	promoted := d.field != nil && d.field.promoted
 
 	// anonymous structs will still work for caching as the whole definition is stored
 	// including tags
 	s, ok := d.d.structCache.Get(typ)
 	if !ok {
-		s = d.d.structCache.parseStruct(typ, d.d.tagName)
+		s = d.d.structCache.parseStruct(typ, d.d.tagNames, d.d.naming, d.d.fold, d.d.isCustomType)
 	}
 
-	for _, f := range s.fields {
+	if s.genDecode && d.plain {
+		g := d.genDecoder()
+		prevStruct := g.s
+		g.s = s
 
-		namespace = namespace[:l]
+		set = v.Addr().Interface().(FormDecoder).DecodeForm(g, string(namespace))
 
-		if first {
-			namespace = append(namespace, f.name...)
-		} else {
-			namespace = append(namespace, namespaceSeparator)
-			namespace = append(namespace, f.name...)
+		g.s = prevStruct
+	} else {
+
+		prev := d.field
+
+		for i := 0; i < len(s.fields); i++ {
+
+			if d.setStructField(v, namespace, &s.fields[i]) {
+				set = true
+			}
 		}
 
-		if d.setFieldByType(v.Field(f.idx), namespace, 0) {
-			set = true
+		d.field = prev
+	}
+
+	// hooks are only called on nested structs when at least one of their fields was set
+	if (set || first) && (s.afterDecode || s.validate) && !promoted {
+		d.callHooks(v, namespace, s)
+	}
+
+	return
+}
+
+// setStructField sets field f of struct v, under the struct's namespace;
+// the caller is responsible for restoring d.field.
+func (d *decoder) setStructField(v reflect.Value, namespace []byte, f *cachedField) (set bool) {
+
+	l := len(namespace)
+
+	if l == 0 {
+		namespace = append(namespace, f.name...)
+	} else {
+		namespace = append(namespace, namespaceSeparator)
+		namespace = append(namespace, f.name...)
+	}
+
+	d.field = f
+
+	fv := v.Field(f.idx)
+
+	if f.scalar != nil && d.scalar && fv.CanSet() {
+		set = d.setScalar(unsafe.Pointer(fv.UnsafeAddr()), namespace, f)
+	} else {
+		set = d.setFieldByType(fv, namespace, 0, f)
+	}
+
+	if len(f.aliases) > 0 {
+		set = d.setAliases(fv, namespace, l, f, set)
+	}
+
+	if !set && d.mode == ModeReplace {
+		zeroField(fv)
+	}
+
+	return
+}
+
+// setScalar sets the string, bool or number field f, which p points to,
+// using its plan; the same as setFieldByType would for a field with
+// no custom type func.
+func (d *decoder) setScalar(p unsafe.Pointer, namespace []byte, f *cachedField) bool {
+
+	arr, ok := d.values[string(namespace)]
+	if !ok || len(arr) == 0 || (len(arr[0]) == 0 && len(f.scalar.err) > 0) {
+		return false
+	}
+
+	if !f.scalar.decode(p, arr[0]) {
+		d.setError(namespace, fmt.Errorf(f.scalar.err, arr[0], f.field.Type, string(namespace)))
+		return false
+	}
+
+	return true
+}
+
+// callHooks calls the AfterFormDecode and Validate methods of struct v, stopping
+// at the first error returned.
+func (d *decoder) callHooks(v reflect.Value, namespace []byte, s *cachedStruct) {
+
+	// an unexported embedded struct can't be passed on
+	if !v.CanAddr() || !v.Addr().CanInterface() {
+		return
+	}
+
+	ptr := v.Addr().Interface()
+
+	if s.afterDecode {
+
+		if err := ptr.(AfterDecoder).AfterFormDecode(); err != nil {
+			d.setError(namespace, err)
+			return
+		}
+	}
+
+	if s.validate {
+
+		if err := ptr.(Validator).Validate(); err != nil {
+			d.setError(namespace, err)
 		}
 	}
+}
+
+// callTypeFunc calls the registered custom type func cf for vals under namespace.
+func (d *decoder) callTypeFunc(cf decodeFunc, namespace []byte, vals []string) (interface{}, error) {
+
+	if cf.ctx == nil {
+		return cf.fn(vals)
+	}
+
+	fc := FieldContext{Context: d.ctx, Namespace: string(namespace)}
+
+	if d.field != nil {
+		fc.Field, fc.Options = d.field.field, d.field.options
+	}
+
+	return cf.ctx(fc, vals)
+}
+
+// setCustomValue sets v to val returned by a custom type func, returning an
+// error rather than panicking when val can't be assigned to v.
+func setCustomValue(v reflect.Value, val interface{}) error {
+
+	if val == nil {
+
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+
+		return fmt.Errorf(errCustomTypeNil, v.Type())
+	}
+
+	rv := reflect.ValueOf(val)
+
+	if !rv.Type().AssignableTo(v.Type()) {
+		return fmt.Errorf(errCustomTypeMismatch, rv.Type(), v.Type())
+	}
+
+	v.Set(rv)
+
+	return nil
+}
+
+// setNamedType sets nil interface v to a new value of the type registered under
+// the name held by its discriminator key, decoding the value under namespace.
+func (d *decoder) setNamedType(v reflect.Value, namespace []byte, f *cachedField) (set bool) {
+
+	l := len(namespace)
+
+	if l > 0 {
+		namespace = append(namespace, namespaceSeparator)
+	}
+
+	namespace = append(namespace, d.d.typeKey...)
+
+	arr, ok := d.values[string(namespace)]
+	if !ok || len(arr) == 0 {
+		return
+	}
+
+	typ, ok := d.d.namedTypes[arr[0]]
+	if !ok {
+
+		// an empty interface falls back to being decoded dynamically
+		if v.NumMethod() > 0 {
+			d.setError(namespace, fmt.Errorf(errUnknownTypeName, arr[0]))
+		}
+
+		return
+	}
+
+	if !typ.AssignableTo(v.Type()) {
+		d.setError(namespace, fmt.Errorf(errTypeNotAssignable, typ, arr[0], v.Type()))
+		return
+	}
+
+	nv := reflect.New(typ).Elem()
+	d.setFieldByType(nv, namespace[:l], 0, f)
+
+	// the discriminator alone is enough to set the type
+	if typ.Kind() == reflect.Ptr && nv.IsNil() {
+		nv.Set(reflect.New(typ.Elem()))
+	}
+
+	v.Set(nv)
+
+	return true
+}
+
+// setOptional sets the state of Optional v, decoding its Value as normal
+// when the value is not null.
+func (d *decoder) setOptional(v reflect.Value, namespace []byte, idx int, f *cachedField, arr []string, ok bool) (set bool) {
+
+	if ok && idx < len(arr) {
+
+		if (len(d.d.nullToken) == 0 && len(arr[idx]) == 0) || (len(d.d.nullToken) > 0 && arr[idx] == d.d.nullToken) {
+			v.Set(reflect.Zero(v.Type()))
+			v.Field(1).SetBool(true)
+			v.Field(2).SetBool(true)
+			return true
+		}
+	}
+
+	if set = d.setValueByType(v.Field(0), namespace, idx, f, arr, ok); set {
+		v.Field(1).SetBool(true)
+		v.Field(2).SetBool(false)
+	}
+
+	return
+}
+
+// setSQLNull sets database/sql Null type v, decoding its value as normal
+// and marking it Valid when the value is not null.
+func (d *decoder) setSQLNull(v reflect.Value, namespace []byte, idx int, f *cachedField, arr []string, ok bool) (set bool) {
+
+	if ok && idx < len(arr) {
+
+		if len(d.d.nullToken) > 0 && arr[idx] == d.d.nullToken {
+			v.Set(reflect.Zero(v.Type()))
+			return true
+		}
+
+		if len(arr[idx]) == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			v.Field(1).SetBool(d.d.nullPolicy == NullEmptyValid)
+			return true
+		}
+	}
+
+	if set = d.setValueByType(v.Field(0), namespace, idx, f, arr, ok); set {
+		v.Field(1).SetBool(true)
+	}
 
 	return
 }
 
-func (d *decoder) setFieldByType(current reflect.Value, namespace []byte, idx int) (set bool) {
+// setEmpty applies the decoder's null token and EmptyPolicy to current when arr[idx]
+// is the null token or empty, returning whether it was handled and current set.
+func (d *decoder) setEmpty(current reflect.Value, kind reflect.Kind, arr []string, idx int) (handled bool, set bool) {
+
+	// a slice, array or map is only empty when it has a single value,
+	// an empty value amongst others is left to its element
+	switch kind {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if len(arr) != 1 {
+			return
+		}
+	}
+
+	value := arr[idx]
+	isNull := len(d.d.nullToken) > 0 && value == d.d.nullToken
+
+	if !isNull && len(value) > 0 {
+		return
+	}
+
+	if isNull || d.d.emptyPolicy == EmptyNil {
+		current.Set(reflect.Zero(current.Type()))
+		return true, true
+	}
+
+	switch kind {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return
+	}
+
+	switch d.d.emptyPolicy {
+	case EmptyIgnore:
+		return true, false
+
+	case EmptyZero:
+
+		if current.Kind() == reflect.Ptr {
+			current.Set(reflect.New(current.Type().Elem()))
+		} else {
+			current.Set(reflect.Zero(current.Type()))
+		}
+
+		return true, true
+	}
+
+	return
+}
+
+// setAliases attempts to set v using the aliases of field f, in order, when it has
+// not already been set using the primary name. namespace holds the primary name
+// of the field after the first l bytes.
+func (d *decoder) setAliases(v reflect.Value, namespace []byte, l int, f *cachedField, set bool) bool {
+
+	used := f.name
+	ns := make([]byte, l, len(namespace)+16)
+	copy(ns, namespace)
+
+	for _, alias := range f.aliases {
+
+		ns = ns[:l]
+
+		if l > 0 {
+			ns = append(ns, namespaceSeparator)
+		}
+
+		ns = append(ns, alias...)
+
+		if !set {
+
+			if set = d.setFieldByType(v, ns, 0, f); set {
+				used = alias
+			}
+
+			continue
+		}
+
+		if d.d.aliasPolicy != AliasStrict {
+			break
+		}
+
+		// only need to know if the alias is present, whether or not its value is valid.
+		if d.present(string(ns)) {
+			d.setError(namespace, fmt.Errorf(errMultipleAliases, used, alias))
+			break
+		}
+	}
+
+	return set
+}
+
+// present returns if the values contain the key ns, or a key nested
+// under it eg. "ns[0]" or "ns.Field".
+func (d *decoder) present(ns string) bool {
+
+	if _, ok := d.values[ns]; ok {
+		return true
+	}
+
+	d.parseMapData()
+
+	if d.findAlias(ns) != nil {
+		return true
+	}
+
+	_, ok := d.dm.parents[ns]
+
+	return ok
+}
+
+// setFieldByType sets current from the values for namespace; f is the struct
+// field being set, if any, and is only passed down to the value of a pointer.
+func (d *decoder) setFieldByType(current reflect.Value, namespace []byte, idx int, f *cachedField) (set bool) {
+
+	arr, ok := d.values[string(namespace)]
+
+	return d.setValueByType(current, namespace, idx, f, arr, ok)
+}
+
+// setValueByType sets current from arr, the values for namespace or those split
+// from a delimited value, ok being whether there were any values for namespace.
+func (d *decoder) setValueByType(current reflect.Value, namespace []byte, idx int, f *cachedField, arr []string, ok bool) (set bool) {
 
 	var err error
 
 	v, kind := ExtractType(current)
 
-	arr, ok := d.values[string(namespace)]
+	if kind == reflect.Struct && isOptional(v.Type()) {
+		return d.setOptional(v, namespace, idx, f, arr, ok)
+	}
 
-	if d.d.customTypeFuncs != nil {
+	// custom type funcs registered for sql Null types take precedence
+	if kind == reflect.Struct && isSQLNull(v.Type()) {
+
+		if _, custom := d.d.typeFunc(v.Type()); !custom {
+			return d.setSQLNull(v, namespace, idx, f, arr, ok)
+		}
+	}
+
+	if ok && idx < len(arr) && (d.d.emptyPolicy != EmptyDefault || len(d.d.nullToken) > 0) {
+
+		if handled, s := d.setEmpty(current, kind, arr, idx); handled {
+			return s
+		}
+	}
+
+	if d.d.hasTypeFuncs() {
 
 		if ok {
 
-			if cf, ok := d.d.customTypeFuncs[v.Type()]; ok {
-				val, err := cf(arr)
+			if cf, ok := d.d.typeFunc(v.Type()); ok {
+
+				// values from idx on, so that an element of a slice gets its own value first
+				val, err := d.callTypeFunc(cf, namespace, arr[idx:])
+				if err == nil {
+					err = setCustomValue(v, val)
+				}
+
 				if err != nil {
 					d.setError(namespace, err)
 					return
 				}
 
-				v.Set(reflect.ValueOf(val))
 				set = true
 				return
 			}
@@ -196,12 +579,24 @@ func (d *decoder) setFieldByType(current reflect.Value, namespace []byte, idx in
 	}
 
 	switch kind {
-	case reflect.Interface, reflect.Invalid:
+	case reflect.Interface:
+
+		if d.d.namedTypes != nil {
+			set = d.setNamedType(v, namespace, f)
+		}
+
+		if !set && v.NumMethod() == 0 {
+			set = d.setDynamic(v, namespace)
+		}
+
+		return
+
+	case reflect.Invalid:
 		return
 	case reflect.Ptr:
 
 		newVal := reflect.New(v.Type().Elem())
-		if set = d.setFieldByType(newVal.Elem(), namespace, idx); set {
+		if set = d.setValueByType(newVal.Elem(), namespace, idx, f, arr, ok); set {
 			v.Set(newVal)
 		}
 
@@ -387,10 +782,15 @@ func (d *decoder) setFieldByType(current reflect.Value, namespace []byte, idx in
 		set = true
 
 	case reflect.Slice, reflect.Array:
-		set = d.setSliceByType(namespace, v, arr, ok)
+		set = d.setSliceByType(namespace, v, arr, ok, f)
 
 	case reflect.Map:
 
+		if isDynamicMap(v.Type()) {
+			set = d.setDynamicMap(v, namespace, f)
+			return
+		}
+
 		var rd *recursiveData
 
 		d.parseMapData()
@@ -407,7 +807,19 @@ func (d *decoder) setFieldByType(current reflect.Value, namespace []byte, idx in
 
 		typ := v.Type()
 
-		if v.IsNil() {
+		if f != nil && (f.max > 0 || f.min > 0) && !d.checkFieldSize(namespace, f, distinctKeys(rd.keys)) {
+			return
+		}
+
+		if max := d.d.limits.MaxMapEntries; max > 0 {
+
+			if d.mapEntries += len(rd.keys); d.mapEntries > max {
+				d.setError(namespace, &LimitError{Limit: "MaxMapEntries", Max: max, Value: d.mapEntries})
+				return
+			}
+		}
+
+		if v.IsNil() || d.mode == ModeReplace {
 			mp = reflect.MakeMap(typ)
 		} else {
 			existing = true
@@ -424,7 +836,7 @@ func (d *decoder) setFieldByType(current reflect.Value, namespace []byte, idx in
 				continue
 			}
 
-			if d.setFieldByType(newVal, append(namespace, kv.searchValue...), 0) {
+			if d.setFieldByType(newVal, append(namespace, kv.searchValue...), 0, nil) {
 				set = true
 				mp.SetMapIndex(mk, newVal)
 			}
@@ -468,7 +880,12 @@ func (d *decoder) setFieldByType(current reflect.Value, namespace []byte, idx in
 	return
 }
 
-func (d *decoder) setSliceByType(namespace []byte, v reflect.Value, arr []string, ok bool) (set bool) {
+func (d *decoder) setSliceByType(namespace []byte, v reflect.Value, arr []string, ok bool, f *cachedField) (set bool) {
+	if !ok {
+		// empty brackets eg. "ids[]=1&ids[]=2", as written by SliceBrackets, are repeated values
+		arr, ok = d.values[string(namespace)+"[]"]
+	}
+
 	if !ok {
 
 		d.parseMapData()
@@ -478,14 +895,46 @@ func (d *decoder) setSliceByType(namespace []byte, v reflect.Value, arr []string
 
 			var varr reflect.Value
 			var kv key
+			var positions map[int]int
 
 			sl := rd.sliceLen + 1
 
+			switch d.d.sparsePolicy {
+			case SparseCompact:
+				positions = compactIndexes(rd.keys)
+				sl = len(positions)
+
+			case SparseReject:
+				if n := len(compactIndexes(rd.keys)); sl > n*d.d.sparseFactor {
+					d.setError(namespace, fmt.Errorf(errSparseArray, sl, n))
+					return
+				}
+			}
+
+			// max bounds the length about to be allocated, while min counts the elements supplied
+			if f != nil && f.max > 0 && sl > f.max {
+				d.setError(namespace, fmt.Errorf(errFieldMax, sl, f.max))
+				return
+			}
+
+			if f != nil && f.min > 0 && !d.checkFieldSize(namespace, f, distinctKeys(rd.keys)) {
+				return
+			}
+
 			// checking below for maxArraySize, but if array exists and already
 			// has sufficient capacity allocated then we do not check as the code
 			// obviously allows a capacity greater than the maxArraySize.
 
-			if v.IsNil() {
+			if v.Kind() == reflect.Array {
+
+				if sl > v.Len() {
+					d.setError(namespace, fmt.Errorf(errArrayLength, sl, v.Len()))
+					return
+				}
+
+				varr = d.arrayValue(v)
+
+			} else if v.IsNil() || d.mode == ModeReplace {
 
 				if sl > d.d.maxArraySize {
 					d.setError(namespace, fmt.Errorf(errArraySize, sl, d.d.maxArraySize))
@@ -524,9 +973,14 @@ func (d *decoder) setSliceByType(namespace []byte, v reflect.Value, arr []string
 					continue
 				}
 
-				if d.setFieldByType(newVal, append(namespace, kv.searchValue...), 0) {
+				if d.setFieldByType(newVal, append(namespace, kv.searchValue...), 0, nil) {
 					set = true
-					varr.Index(kv.ivalue).Set(newVal)
+
+					if positions != nil {
+						varr.Index(positions[kv.ivalue]).Set(newVal)
+					} else {
+						varr.Index(kv.ivalue).Set(newVal)
+					}
 				}
 			}
 
@@ -540,14 +994,39 @@ func (d *decoder) setSliceByType(namespace []byte, v reflect.Value, arr []string
 		return
 	}
 
+	// the split values are passed to each element, never written back to the values
+	if delim, trim := d.sliceDelimiter(f); len(delim) > 0 {
+		arr = splitDelimited(arr, delim, trim)
+	}
+
 	if len(arr) == 0 {
 		return
 	}
 
+	// values split from a delimited value are checked again, as they weren't counted by checkLimits
+	if max := d.d.limits.MaxValues; max > 0 && len(arr) > max {
+		d.setError(namespace, &LimitError{Limit: "MaxValues", Max: max, Value: len(arr)})
+		return
+	}
+
+	if !d.checkFieldSize(namespace, f, len(arr)) {
+		return
+	}
+
 	var varr reflect.Value
 	var existing bool
 
-	if v.IsNil() {
+	if v.Kind() == reflect.Array {
+
+		if len(arr) > v.Len() {
+			d.setError(namespace, fmt.Errorf(errArrayLength, len(arr), v.Len()))
+			return
+		}
+
+		varr = d.arrayValue(v)
+		existing = d.mode != ModeReplace
+
+	} else if v.IsNil() || d.mode == ModeReplace {
 		varr = reflect.MakeSlice(v.Type(), len(arr), len(arr))
 	} else if v.Len() < len(arr) {
 		if v.Cap() <= len(arr) {
@@ -564,7 +1043,7 @@ func (d *decoder) setSliceByType(namespace []byte, v reflect.Value, arr []string
 	for i := 0; i < len(arr); i++ {
 		newVal := reflect.New(v.Type().Elem()).Elem()
 
-		if d.setFieldByType(newVal, namespace, i) {
+		if d.setValueByType(newVal, namespace, i, nil, arr, true) {
 			set = true
 			varr.Index(i).Set(newVal)
 		}
@@ -578,20 +1057,72 @@ func (d *decoder) setSliceByType(namespace []byte, v reflect.Value, arr []string
 	return
 }
 
+// sliceDelimiter returns the delimiter, if any, used to split the values of a
+// slice or array field f along with whether to trim space from each part.
+func (d *decoder) sliceDelimiter(f *cachedField) (string, bool) {
+
+	if f != nil && f.style == SliceDelimited {
+
+		if len(f.delim) > 0 {
+			return f.delim, f.trim
+		}
+
+		if len(d.d.sliceDelim) > 0 {
+			return d.d.sliceDelim, f.trim || d.d.sliceTrim
+		}
+
+		return ",", f.trim
+	}
+
+	return d.d.sliceDelim, d.d.sliceTrim || (f != nil && f.trim)
+}
+
+// arrayValue returns the fixed length array v to set elements on directly,
+// or a new zeroed array to replace it with when in ModeReplace.
+func (d *decoder) arrayValue(v reflect.Value) reflect.Value {
+
+	if d.mode == ModeReplace {
+		return reflect.New(v.Type()).Elem()
+	}
+
+	return v
+}
+
+// checkFieldSize checks the size of a slice, array or map against the max
+// and min tag options of the field f, if any.
+func (d *decoder) checkFieldSize(namespace []byte, f *cachedField, size int) bool {
+
+	if f == nil {
+		return true
+	}
+
+	if f.max > 0 && size > f.max {
+		d.setError(namespace, fmt.Errorf(errFieldMax, size, f.max))
+		return false
+	}
+
+	if size < f.min {
+		d.setError(namespace, fmt.Errorf(errFieldMin, size, f.min))
+		return false
+	}
+
+	return true
+}
+
 func (d *decoder) getMapKey(key string, current reflect.Value, namespace []byte) (err error) {
 
 	v, kind := ExtractType(current)
 
-	if d.d.customTypeFuncs != nil {
-		if cf, ok := d.d.customTypeFuncs[v.Type()]; ok {
+	if d.d.hasTypeFuncs() {
+		if cf, ok := d.d.typeFunc(v.Type()); ok {
 
-			val, er := cf([]string{key})
+			val, er := d.callTypeFunc(cf, namespace, []string{key})
 			if er != nil {
 				err = er
 				return
 			}
 
-			v.Set(reflect.ValueOf(val))
+			err = setCustomValue(v, val)
 			return
 		}
 	}
@@ -727,3 +1258,40 @@ func (d *decoder) getMapKey(key string, current reflect.Value, namespace []byte)
 
 	return
 }
+
+// compactIndexes returns the position, in a dense slice, of each distinct
+// valid array index within keys, preserving their relative order.
+func compactIndexes(keys []key) map[int]int {
+
+	indexes := make([]int, 0, len(keys))
+
+	for i := 0; i < len(keys); i++ {
+		if keys[i].ivalue != -1 {
+			indexes = append(indexes, keys[i].ivalue)
+		}
+	}
+
+	sort.Ints(indexes)
+
+	positions := make(map[int]int, len(indexes))
+
+	for _, idx := range indexes {
+		if _, ok := positions[idx]; !ok {
+			positions[idx] = len(positions)
+		}
+	}
+
+	return positions
+}
+
+// distinctKeys returns the number of distinct map keys within keys.
+func distinctKeys(keys []key) int {
+
+	seen := make(map[string]struct{}, len(keys))
+
+	for i := 0; i < len(keys); i++ {
+		seen[keys[i].value] = struct{}{}
+	}
+
+	return len(seen)
+}
@@ -1,8 +1,13 @@
 package form
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -1307,3 +1312,1643 @@ func TestDecoderIncreasingKeys(t *testing.T) {
 	Equal(t, test2.Array[2], int(2))
 	Equal(t, test2.Array[10], int(10))
 }
+
+func TestDecoderReplaceMode(t *testing.T) {
+
+	type Phone struct {
+		Number string
+	}
+
+	type TestStruct struct {
+		Name      string
+		Age       int
+		Phone     *Phone
+		Tags      []string
+		Indexed   []string
+		Map       map[string]string
+		Untouched string
+	}
+
+	values := url.Values{
+		"Name":       []string{"joeybloggs"},
+		"Tags":       []string{"a"},
+		"Indexed[1]": []string{"b"},
+		"Map[key]":   []string{"value"},
+	}
+
+	existing := func() TestStruct {
+		return TestStruct{
+			Name:      "old",
+			Age:       3,
+			Phone:     &Phone{Number: "1(111)111-1111"},
+			Tags:      []string{"x", "y", "z"},
+			Indexed:   []string{"x", "y", "z"},
+			Map:       map[string]string{"existing": "value"},
+			Untouched: "untouched",
+		}
+	}
+
+	decoder := NewDecoder()
+
+	test := existing()
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Name, "joeybloggs")
+	Equal(t, test.Age, 3)
+	NotEqual(t, test.Phone, nil)
+	Equal(t, test.Tags, []string{"a", "y", "z"})
+	Equal(t, test.Indexed, []string{"x", "b", "z"})
+	Equal(t, len(test.Map), 2)
+	Equal(t, test.Untouched, "untouched")
+
+	test = existing()
+	errs = decoder.DecodeWithMode(&test, values, ModeReplace)
+	Equal(t, errs, nil)
+	Equal(t, test.Name, "joeybloggs")
+	Equal(t, test.Age, 0)
+	Equal(t, test.Phone, nil)
+	Equal(t, test.Tags, []string{"a"})
+	Equal(t, test.Indexed, []string{"", "b"})
+	Equal(t, len(test.Map), 1)
+	Equal(t, test.Map["key"], "value")
+	Equal(t, test.Untouched, "")
+
+	decoder.SetMode(ModeReplace)
+
+	test = existing()
+	test.Phone = &Phone{}
+	errs = decoder.Decode(&test, url.Values{"Phone.Number": []string{"2(222)222-2222"}})
+	Equal(t, errs, nil)
+	Equal(t, test.Name, "")
+	Equal(t, test.Phone.Number, "2(222)222-2222")
+	Equal(t, test.Tags, nil)
+	Equal(t, test.Map, nil)
+
+	test = existing()
+	errs = decoder.DecodeWithMode(&test, url.Values{"Name": []string{"joeybloggs"}}, ModeMerge)
+	Equal(t, errs, nil)
+	Equal(t, test.Name, "joeybloggs")
+	Equal(t, test.Age, 3)
+	Equal(t, len(test.Tags), 3)
+
+	// unexported embedded structs can't be set, so only their exported fields are zeroed
+	type base struct {
+		Base string
+	}
+
+	type phone struct {
+		Number string
+	}
+
+	type Embedded struct {
+		base
+		*phone
+		Name string
+	}
+
+	embedded := Embedded{base: base{Base: "base"}, phone: &phone{Number: "1"}, Name: "name"}
+
+	errs = decoder.Decode(&embedded, url.Values{"Name": []string{"joeybloggs"}})
+	Equal(t, errs, nil)
+	Equal(t, embedded.Name, "joeybloggs")
+	Equal(t, embedded.Base, "")
+	Equal(t, embedded.Number, "")
+}
+
+func TestDecoderCaseFolding(t *testing.T) {
+
+	type Nested struct {
+		Value string
+	}
+
+	type TestStruct struct {
+		UserName string
+		Email    string `form:"email"`
+		Nested   Nested
+		Nesteds  []Nested
+		Map      map[string]int
+		Straße   string
+	}
+
+	values := url.Values{
+		"username":         []string{"joeybloggs"},
+		"EMAIL":            []string{"joeybloggs@gmail.com"},
+		"nested.VALUE":     []string{"value"},
+		"NESTEDS[0].value": []string{"value0"},
+		"map[Key]":         []string{"1"},
+		"STRASSE":          []string{"ascii only"},
+		"STRAẞE":           []string{"unicode"},
+	}
+
+	var test TestStruct
+
+	decoder := NewDecoder()
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.UserName, "")
+	Equal(t, test.Email, "")
+
+	decoder.SetCaseFolding(FoldASCII)
+
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.UserName, "joeybloggs")
+	Equal(t, test.Email, "joeybloggs@gmail.com")
+	Equal(t, test.Nested.Value, "value")
+	Equal(t, len(test.Nesteds), 1)
+	Equal(t, test.Nesteds[0].Value, "value0")
+	Equal(t, len(test.Map), 1)
+	Equal(t, test.Map["Key"], 1)
+	Equal(t, test.Straße, "")
+
+	decoder.SetCaseFolding(FoldUnicode)
+
+	test = TestStruct{}
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.UserName, "joeybloggs")
+	Equal(t, test.Straße, "unicode")
+
+	values = url.Values{
+		"username": []string{"joeybloggs"},
+		"UserName": []string{"joeybloggs2"},
+		"Map[key]": []string{"bad"},
+	}
+
+	test = TestStruct{}
+	errs = decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+	Equal(t, test.UserName, "joeybloggs2")
+
+	// combined in sorted key order, whatever the map order
+	for i := 0; i < 50; i++ {
+		folded := foldValues(values, FoldASCII)
+		Equal(t, folded["username"], []string{"joeybloggs2", "joeybloggs"})
+	}
+	Equal(t, errs.(DecodeErrors)["map[key]"].Error(), "Invalid Integer Value 'bad' Type 'int' Namespace 'map[key]'")
+}
+
+func TestDecoderNamingFunc(t *testing.T) {
+
+	type Address struct {
+		StreetName string
+	}
+
+	type TestStruct struct {
+		FirstName string
+		LastName  string `form:"surname"`
+		UserID    int
+		Addresses []Address
+	}
+
+	values := url.Values{
+		"first_name":               []string{"Joey"},
+		"surname":                  []string{"Bloggs"},
+		"user_id":                  []string{"3"},
+		"addresses[0].street_name": []string{"26 Here Blvd."},
+	}
+
+	var test TestStruct
+
+	decoder := NewDecoder()
+	decoder.SetNamingFunc(SnakeCase)
+
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.FirstName, "Joey")
+	Equal(t, test.LastName, "Bloggs")
+	Equal(t, test.UserID, 3)
+	Equal(t, len(test.Addresses), 1)
+	Equal(t, test.Addresses[0].StreetName, "26 Here Blvd.")
+}
+
+func TestDecoderTagNames(t *testing.T) {
+
+	type TestStruct struct {
+		Name     string `form:"name" json:"full_name"`
+		Email    string `json:"email,omitempty"`
+		Age      int    `json:",omitempty"`
+		Ignored  string `json:"-"`
+		Dash     string `json:"-,"`
+		FormOnly string `form:"-" json:"form_only"`
+	}
+
+	values := url.Values{
+		"name":      []string{"joeybloggs"},
+		"full_name": []string{"wrong"},
+		"email":     []string{"joeybloggs@gmail.com"},
+		"Age":       []string{"3"},
+		"Ignored":   []string{"ignored"},
+		"-":         []string{"dash"},
+		"form_only": []string{"ignored"},
+	}
+
+	var test TestStruct
+
+	decoder := NewDecoder()
+	decoder.SetTagNames("form", "json")
+
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Name, "joeybloggs")
+	Equal(t, test.Email, "joeybloggs@gmail.com")
+	Equal(t, test.Age, 3)
+	Equal(t, test.Ignored, "")
+	Equal(t, test.Dash, "dash")
+	Equal(t, test.FormOnly, "")
+}
+
+func TestDecoderAliases(t *testing.T) {
+
+	type Filter struct {
+		Value string
+	}
+
+	type TestStruct struct {
+		Query  string `form:"query|q"`
+		Limit  int    `form:"limit|max|l"`
+		Filter Filter `form:"filter|f"`
+		IDs    []int  `form:"ids|id"`
+		Single string `form:"single"`
+	}
+
+	values := url.Values{
+		"q":       []string{"search"},
+		"l":       []string{"10"},
+		"max":     []string{"20"},
+		"f.Value": []string{"filter"},
+		"id":      []string{"1", "2"},
+	}
+
+	var test TestStruct
+
+	decoder := NewDecoder()
+
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Query, "search")
+	Equal(t, test.Limit, 20)
+	Equal(t, test.Filter.Value, "filter")
+	Equal(t, test.IDs, []int{1, 2})
+
+	values["query"] = []string{"primary"}
+
+	test = TestStruct{}
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Query, "primary")
+
+	decoder.SetAliasPolicy(AliasStrict)
+
+	test = TestStruct{}
+	errs = decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+
+	err := errs.(DecodeErrors)
+	Equal(t, len(err), 2)
+	Equal(t, err["query"].Error(), "Multiple aliases 'query' and 'q' present for a single field")
+	Equal(t, err["limit"].Error(), "Multiple aliases 'max' and 'l' present for a single field")
+	Equal(t, test.Query, "primary")
+	Equal(t, test.Limit, 20)
+	Equal(t, test.Filter.Value, "filter")
+
+	encoder := NewEncoder()
+
+	encoded, errs := encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, encoded["query"], []string{"primary"})
+	Equal(t, encoded["limit"], []string{"20"})
+	Equal(t, encoded["filter.Value"], []string{"filter"})
+	Equal(t, encoded["ids"], []string{"1", "2"})
+
+	// conflicts are found from the keys alone, without decoding the alias, so an
+	// invalid alias value is still a conflict and isn't reported as a conversion error
+	values = url.Values{
+		"limit":   []string{"1"},
+		"max":     []string{"bad"},
+		"f.Value": []string{"filter"},
+		"ids[0]":  []string{"1"},
+		"id[1]":   []string{"2"},
+	}
+
+	test = TestStruct{}
+	errs = decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+
+	err = errs.(DecodeErrors)
+	Equal(t, len(err), 2)
+	Equal(t, err["limit"].Error(), "Multiple aliases 'limit' and 'max' present for a single field")
+	Equal(t, err["ids"].Error(), "Multiple aliases 'ids' and 'id' present for a single field")
+	Equal(t, test.Limit, 1)
+	Equal(t, test.IDs, []int{1})
+
+	// nested aliases conflict, a key merely sharing an alias's prefix doesn't
+	values = url.Values{
+		"filter.Value": []string{"primary"},
+		"f.Value":      []string{"filter"},
+		"idx[0]":       []string{"1"},
+		"ids[0]":       []string{"2"},
+	}
+
+	test = TestStruct{}
+	errs = decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+
+	err = errs.(DecodeErrors)
+	Equal(t, len(err), 1)
+	Equal(t, err["filter"].Error(), "Multiple aliases 'filter' and 'f' present for a single field")
+	Equal(t, test.Filter.Value, "primary")
+	Equal(t, test.IDs, []int{2})
+
+	// nor are custom type funcs called for it
+	var calls int
+
+	decoder.RegisterCustomTypeFunc(func(vals []string) (interface{}, error) {
+		calls++
+		return strconv.Atoi(vals[0])
+	}, 0)
+
+	test = TestStruct{}
+	errs = decoder.Decode(&test, url.Values{"limit": []string{"1"}, "max": []string{"2"}})
+	NotEqual(t, errs, nil)
+	Equal(t, calls, 1)
+	Equal(t, test.Limit, 1)
+}
+
+func TestDecoderLimits(t *testing.T) {
+
+	type Nested struct {
+		Value string
+		Map   map[string]string
+	}
+
+	type TestStruct struct {
+		Name   string
+		Nested Nested
+		Array  [][]int
+		Map    map[string]string
+		Many   []string
+	}
+
+	values := url.Values{
+		"Name":             []string{"joeybloggs"},
+		"Nested.Value":     []string{"value"},
+		"Nested.Map[a]":    []string{"1"},
+		"Array[0][1]":      []string{"1"},
+		"Map[a]":           []string{"1"},
+		"Map[b]":           []string{"2"},
+		"Map[c]":           []string{"3"},
+		"Many":             []string{"1", "2", "3"},
+		"Nested.Map[long]": []string{"longer than ten"},
+	}
+
+	decoder := NewDecoder()
+	decoder.SetLimits(Limits{MaxKeys: 8})
+
+	var test TestStruct
+	errs := decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+
+	err := errs.(DecodeErrors)
+	Equal(t, len(err), 1)
+	Equal(t, err[""].Error(), "Limit MaxKeys of '8' exceeded with '9'")
+	Equal(t, test.Name, "")
+
+	decoder.SetLimits(Limits{MaxValues: 10})
+
+	errs = decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+
+	err = errs.(DecodeErrors)
+	Equal(t, len(err), 1)
+	Equal(t, err[""].(*LimitError).Limit, "MaxValues")
+	Equal(t, err[""].(*LimitError).Value, 11)
+
+	decoder.SetLimits(Limits{
+		MaxKeys:        9,
+		MaxValues:      11,
+		MaxValueLength: 10,
+		MaxDepth:       2,
+		MaxKeySegments: 1,
+		MaxMapEntries:  3,
+	})
+
+	errs = decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+
+	err = errs.(DecodeErrors)
+	Equal(t, len(err), 3)
+	Equal(t, err["Nested.Map[long]"].Error(), "Limit MaxValueLength of '10' exceeded with '15'")
+	Equal(t, err["Array[0][1]"].Error(), "Limit MaxKeySegments of '1' exceeded with '2'")
+	Equal(t, err["Nested.Map[a]"].Error(), "Limit MaxDepth of '2' exceeded with '3'")
+	Equal(t, test.Name, "joeybloggs")
+	Equal(t, test.Nested.Value, "value")
+	Equal(t, test.Nested.Map, nil)
+	Equal(t, test.Array, nil)
+	Equal(t, len(test.Map), 3)
+	Equal(t, len(values), 9)
+
+	decoder.SetLimits(Limits{MaxMapEntries: 2})
+
+	test = TestStruct{}
+	errs = decoder.Decode(&test, url.Values{"Map[a]": {"1"}, "Map[b]": {"2"}, "Map[c]": {"3"}})
+	NotEqual(t, errs, nil)
+
+	err = errs.(DecodeErrors)
+	Equal(t, len(err), 1)
+	Equal(t, err["Map"].Error(), "Limit MaxMapEntries of '2' exceeded with '3'")
+	Equal(t, test.Map, nil)
+
+	// the max array size only applies to indexes, repeated values are limited by MaxValues
+	decoder.SetLimits(Limits{})
+	decoder.SetMaxArraySize(2)
+
+	test = TestStruct{}
+	errs = decoder.Decode(&test, url.Values{"Many": {"a", "b", "c"}})
+	Equal(t, errs, nil)
+	Equal(t, len(test.Many), 3)
+
+	// values split from a delimited value count towards MaxValues too
+	var delimited struct {
+		IDs []int `form:"ids,delim=,"`
+	}
+
+	decoder.SetLimits(Limits{MaxValues: 2})
+
+	errs = decoder.Decode(&delimited, url.Values{"ids": {"1,2,3"}})
+	NotEqual(t, errs, nil)
+
+	err = errs.(DecodeErrors)
+	Equal(t, len(err), 1)
+	Equal(t, err["ids"].Error(), "Limit MaxValues of '2' exceeded with '3'")
+	Equal(t, delimited.IDs, nil)
+}
+
+func TestDecoderSparsePolicy(t *testing.T) {
+
+	type Item struct {
+		Name string
+	}
+
+	type TestStruct struct {
+		Items []Item
+		Ints  []int
+	}
+
+	values := url.Values{
+		"Items[9999].Name": []string{"last"},
+		"Items[3].Name":    []string{"first"},
+		"Ints[5]":          []string{"5"},
+		"Ints[1]":          []string{"1"},
+		"Ints[2]":          []string{"2"},
+	}
+
+	var test TestStruct
+
+	decoder := NewDecoder()
+
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, len(test.Items), 10000)
+	Equal(t, len(test.Ints), 6)
+
+	decoder.SetSparsePolicy(SparseCompact, 0)
+
+	test = TestStruct{}
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, len(test.Items), 2)
+	Equal(t, test.Items[0].Name, "first")
+	Equal(t, test.Items[1].Name, "last")
+	Equal(t, test.Ints, []int{1, 2, 5})
+
+	decoder.SetSparsePolicy(SparseReject, 2)
+
+	test = TestStruct{}
+	errs = decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+
+	err := errs.(DecodeErrors)
+	Equal(t, len(err), 1)
+	Equal(t, err["Items"].Error(), "Array size of '10000' is too sparse for the '2' elements provided. To change this policy please see, SetSparsePolicy(policy SparsePolicy, factor uint)")
+	Equal(t, len(test.Items), 0)
+	Equal(t, test.Ints, []int{0, 1, 2, 0, 0, 5})
+
+	values = url.Values{
+		"Ints[99999999999999999999]": []string{"1"},
+	}
+
+	decoder = NewDecoder()
+
+	test = TestStruct{}
+	errs = decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+	Equal(t, errs.(DecodeErrors)["Ints"].Error(), "Invalid Array index '99999999999999999999'")
+}
+
+func TestDecoderFieldSizeLimits(t *testing.T) {
+
+	type TestStruct struct {
+		Tags     []string          `form:"tags,max=2"`
+		Indexed  []int             `form:"indexed,max=2"`
+		Required []string          `form:"required,min=2"`
+		Map      map[string]string `form:"map,min=1,max=1"`
+		Array    [2]int
+		Nested   [][]string `form:"nested,max=1"`
+		Missing  []string   `form:"missing,min=1"`
+		Sparse   []int      `form:"sparse,min=2"`
+	}
+
+	values := url.Values{
+		"tags":         []string{"a", "b", "c"},
+		"indexed[5]":   []string{"5"},
+		"sparse[7]":    []string{"7"},
+		"required":     []string{"a"},
+		"map[a]":       []string{"1"},
+		"map[b]":       []string{"2"},
+		"Array[1]":     []string{"1"},
+		"nested[0][0]": []string{"a"},
+		"nested[0][1]": []string{"b"},
+		"nested[0][2]": []string{"c"},
+	}
+
+	var test TestStruct
+
+	decoder := NewDecoder()
+
+	errs := decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+
+	err := errs.(DecodeErrors)
+	Equal(t, len(err), 5)
+	Equal(t, err["tags"].Error(), "Size of '3' is larger than the maximum of '2' set on the field")
+	Equal(t, err["indexed"].Error(), "Size of '6' is larger than the maximum of '2' set on the field")
+	Equal(t, err["sparse"].Error(), "Size of '1' is smaller than the minimum of '2' set on the field")
+	Equal(t, err["required"].Error(), "Size of '1' is smaller than the minimum of '2' set on the field")
+	Equal(t, err["map"].Error(), "Size of '2' is larger than the maximum of '1' set on the field")
+	Equal(t, test.Tags, nil)
+	Equal(t, test.Indexed, nil)
+	Equal(t, test.Required, nil)
+	Equal(t, test.Map, nil)
+	Equal(t, test.Array, [2]int{0, 1})
+	Equal(t, test.Nested, [][]string{{"a", "b", "c"}})
+	Equal(t, test.Missing, nil)
+	Equal(t, test.Sparse, nil)
+
+	// max bounds the length allocated for indexed keys, however few are supplied
+	var indexed TestStruct
+
+	errs = decoder.Decode(&indexed, url.Values{"tags[9999]": []string{"x"}})
+	NotEqual(t, errs, nil)
+	Equal(t, errs.(DecodeErrors)["tags"].Error(), "Size of '10000' is larger than the maximum of '2' set on the field")
+	Equal(t, indexed.Tags, nil)
+
+	values = url.Values{
+		"Array": []string{"1", "2", "3"},
+	}
+
+	errs = decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+	Equal(t, errs.(DecodeErrors)["Array"].Error(), "Array size of '3' is larger than the fixed array length of '2'")
+
+	values = url.Values{
+		"Array": []string{"3"},
+	}
+
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Array, [2]int{3, 1})
+
+	type BadTag struct {
+		Tags []string `form:"tags,max=none"`
+	}
+
+	PanicMatches(t, func() { decoder.Decode(&BadTag{}, values) }, "Invalid tag option 'max=none' on field 'Tags'")
+}
+
+func TestDecoderDelimitedSlices(t *testing.T) {
+
+	type TestStruct struct {
+		IDs     []int       `form:"ids,delim=,"`
+		Pipe    []string    `form:"pipe,delim=pipe"`
+		Space   []string    `form:"space,delim=space"`
+		Tab     []string    `form:"tab,delim=tab"`
+		Trimmed []string    `form:"trimmed,delim=,,trim"`
+		Escaped []string    `form:"escaped,delim=,"`
+		Times   []time.Time `form:"times,delim=;"`
+		Array   [3]int      `form:"array,delim=,"`
+		Plain   []string    `form:"plain"`
+		Max     []int       `form:"max,delim=,,max=2"`
+	}
+
+	values := url.Values{
+		"ids":     []string{"1,2", "3"},
+		"pipe":    []string{"a|b|c"},
+		"space":   []string{"a b"},
+		"tab":     []string{"a\tb"},
+		"trimmed": []string{" a , b "},
+		"escaped": []string{`a\,b,c\\d,e\f`},
+		"times":   []string{"2016-01-02;2016-01-03"},
+		"array":   []string{"1,2,3"},
+		"plain":   []string{"a,b"},
+		"max":     []string{"1,2,3"},
+	}
+
+	var test TestStruct
+
+	decoder := NewDecoder()
+	decoder.RegisterCustomTypeFunc(func(vals []string) (interface{}, error) {
+		return time.Parse("2006-01-02", vals[0])
+	}, time.Time{})
+
+	errs := decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+
+	err := errs.(DecodeErrors)
+	Equal(t, len(err), 1)
+	Equal(t, err["max"].Error(), "Size of '3' is larger than the maximum of '2' set on the field")
+
+	Equal(t, test.IDs, []int{1, 2, 3})
+	Equal(t, test.Pipe, []string{"a", "b", "c"})
+	Equal(t, test.Space, []string{"a", "b"})
+	Equal(t, test.Tab, []string{"a", "b"})
+	Equal(t, test.Trimmed, []string{"a", "b"})
+	Equal(t, test.Escaped, []string{"a,b", `c\d`, `e\f`})
+	Equal(t, len(test.Times), 2)
+	Equal(t, test.Times[0].Format("2006-01-02"), "2016-01-02")
+	Equal(t, test.Times[1].Format("2006-01-02"), "2016-01-03")
+	Equal(t, test.Array, [3]int{1, 2, 3})
+	Equal(t, test.Plain, []string{"a,b"})
+	Equal(t, values["ids"], []string{"1,2", "3"})
+
+	type TestDecoderWide struct {
+		IDs  []int
+		Tags []string
+	}
+
+	decoder = NewDecoder()
+	decoder.SetSliceDelimiter("|", true)
+
+	var test2 TestDecoderWide
+
+	errs = decoder.Decode(&test2, url.Values{"IDs": {"1| 2"}, "Tags": {""}})
+	Equal(t, errs, nil)
+	Equal(t, test2.IDs, []int{1, 2})
+	Equal(t, test2.Tags, nil)
+
+	encoder := NewEncoder()
+
+	test.Escaped = []string{"a,b", `c\d`, "e"}
+
+	encoded, errs := encoder.Encode(test)
+	Equal(t, errs, nil)
+
+	test = TestStruct{}
+	errs = NewDecoder().Decode(&test, encoded)
+	Equal(t, errs, nil)
+	Equal(t, test.Escaped, []string{"a,b", `c\d`, "e"})
+	Equal(t, test.IDs, []int{1, 2, 3})
+
+	type Item struct {
+		IDs  []string `form:"ids,delim=,"`
+		Name string
+	}
+
+	type TestItems struct {
+		Items []Item
+	}
+
+	var items TestItems
+
+	values = url.Values{"Items[0].ids": []string{`a\,b,c`}, "Items[0].Name": []string{"x"}}
+
+	errs = NewDecoder().Decode(&items, values)
+	Equal(t, errs, nil)
+	Equal(t, len(items.Items), 1)
+	Equal(t, items.Items[0].IDs, []string{"a,b", "c"})
+	Equal(t, items.Items[0].Name, "x")
+	Equal(t, values["Items[0].ids"], []string{`a\,b,c`})
+}
+
+func TestDecoderEmptyPolicy(t *testing.T) {
+
+	type Phone struct {
+		Number string
+	}
+
+	type TestStruct struct {
+		String    string
+		Int       int
+		IntPtr    *int
+		StringPtr *string
+		Phone     *Phone
+		Ints      []int
+		Strings   []string
+		Map       map[string]*int
+		Time      time.Time
+	}
+
+	values := url.Values{
+		"String":    []string{""},
+		"Int":       []string{""},
+		"IntPtr":    []string{""},
+		"StringPtr": []string{""},
+		"Phone":     []string{""},
+		"Ints":      []string{"1", ""},
+		"Strings":   []string{""},
+		"Map[key]":  []string{""},
+		"Time":      []string{""},
+	}
+
+	i := 3
+	s := "str"
+
+	existing := func() TestStruct {
+		return TestStruct{
+			String:    "str",
+			Int:       3,
+			IntPtr:    &i,
+			StringPtr: &s,
+			Phone:     &Phone{Number: "1(111)111-1111"},
+		}
+	}
+
+	decoder := NewDecoder()
+
+	test := existing()
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.String, "")
+	Equal(t, test.Int, 3)
+	Equal(t, *test.IntPtr, 3)
+	Equal(t, *test.StringPtr, "")
+	Equal(t, test.Phone.Number, "1(111)111-1111")
+	Equal(t, test.Ints, []int{1, 0})
+	Equal(t, test.Strings, []string{""})
+
+	s = "str"
+	decoder.SetEmptyPolicy(EmptyIgnore)
+
+	test = existing()
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.String, "str")
+	Equal(t, test.Int, 3)
+	Equal(t, *test.StringPtr, "str")
+	Equal(t, test.Ints, []int{1, 0})
+	Equal(t, test.Strings, nil)
+	Equal(t, test.Map, nil)
+
+	decoder.SetEmptyPolicy(EmptyZero)
+
+	test = existing()
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.String, "")
+	Equal(t, test.Int, 0)
+	Equal(t, *test.IntPtr, 0)
+	Equal(t, i, 3)
+	Equal(t, *test.StringPtr, "")
+	Equal(t, test.Phone.Number, "")
+	Equal(t, test.Strings, []string{""})
+	Equal(t, *test.Map["key"], 0)
+	Equal(t, test.Time.IsZero(), true)
+
+	decoder.SetEmptyPolicy(EmptyNil)
+
+	test = existing()
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.String, "")
+	Equal(t, test.Int, 0)
+	Equal(t, test.IntPtr, nil)
+	Equal(t, test.StringPtr, nil)
+	Equal(t, test.Phone, nil)
+	Equal(t, test.Ints, []int{1, 0})
+	Equal(t, test.Strings, nil)
+	Equal(t, len(test.Map), 1)
+	Equal(t, test.Map["key"], nil)
+
+	// an empty value amongst others only empties its element
+	test = TestStruct{}
+	errs = decoder.Decode(&test, url.Values{"Strings": []string{"", "a"}, "Ints": []string{"", "2"}})
+	Equal(t, errs, nil)
+	Equal(t, test.Strings, []string{"", "a"})
+	Equal(t, test.Ints, []int{0, 2})
+}
+
+func TestDecoderNullToken(t *testing.T) {
+
+	type Phone struct {
+		Number string
+	}
+
+	type TestStruct struct {
+		String  string
+		Int     int
+		IntPtr  *int
+		Phone   *Phone
+		Ints    []int
+		Ptrs    []*int
+		Map     map[string]string
+		Time    *time.Time
+		Missing *int
+	}
+
+	i := 3
+
+	test := TestStruct{
+		IntPtr: &i,
+		Phone:  &Phone{Number: "1(111)111-1111"},
+		Ptrs:   []*int{&i, nil},
+		Time:   &time.Time{},
+	}
+
+	encoder := NewEncoder()
+	encoder.SetNullToken("null")
+
+	values, errs := encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, values["IntPtr"], []string{"3"})
+	Equal(t, values["Ptrs[0]"], []string{"3"})
+	Equal(t, values["Ptrs[1]"], []string{"null"})
+	Equal(t, values["Missing"], []string{"null"})
+
+	values = url.Values{
+		"String":  []string{"null"},
+		"Int":     []string{"null"},
+		"IntPtr":  []string{"null"},
+		"Phone":   []string{"null"},
+		"Ints":    []string{"null"},
+		"Ptrs[0]": []string{"3"},
+		"Ptrs[1]": []string{"null"},
+		"Map":     []string{"null"},
+		"Time":    []string{"null"},
+	}
+
+	test = TestStruct{
+		String: "str",
+		Int:    3,
+		Ints:   []int{1},
+		Map:    map[string]string{"key": "value"},
+	}
+
+	decoder := NewDecoder()
+	decoder.RegisterCustomTypeFunc(func(vals []string) (interface{}, error) {
+		return time.Parse("2006-01-02", vals[0])
+	}, time.Time{})
+	decoder.SetNullToken("null")
+
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.String, "")
+	Equal(t, test.Int, 0)
+	Equal(t, test.IntPtr, nil)
+	Equal(t, test.Phone, nil)
+	Equal(t, test.Ints, nil)
+	Equal(t, len(test.Ptrs), 2)
+	Equal(t, *test.Ptrs[0], 3)
+	Equal(t, test.Ptrs[1], nil)
+	Equal(t, test.Map, nil)
+	Equal(t, test.Time, nil)
+}
+
+func TestDecoderOptional(t *testing.T) {
+
+	type Phone struct {
+		Number string
+	}
+
+	type TestStruct struct {
+		Name     Optional[string]
+		Age      Optional[int]
+		Nick     Optional[string]
+		Missing  Optional[int]
+		Phone    Optional[Phone]
+		PhonePtr *Optional[Phone]
+		IDs      Optional[[]int]
+		Ints     []Optional[int]
+		Map      map[string]Optional[int]
+	}
+
+	values := url.Values{
+		"Name":         []string{"Joey"},
+		"Age":          []string{""},
+		"Nick":         []string{"null"},
+		"Phone.Number": []string{"1(111)111-1111"},
+		"PhonePtr":     []string{""},
+		"IDs":          []string{"1", "2"},
+		"Ints[0]":      []string{"1"},
+		"Ints[2]":      []string{""},
+		"Map[a]":       []string{"3"},
+		"Map[b]":       []string{""},
+	}
+
+	var test TestStruct
+
+	decoder := NewDecoder()
+
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Name, Some("Joey"))
+	Equal(t, test.Age, Null[int]())
+	Equal(t, test.Nick, Some("null"))
+	Equal(t, test.Missing, Optional[int]{})
+	Equal(t, test.Phone, Some(Phone{Number: "1(111)111-1111"}))
+	Equal(t, *test.PhonePtr, Null[Phone]())
+	Equal(t, test.IDs, Some([]int{1, 2}))
+	Equal(t, test.Ints, []Optional[int]{Some(1), {}, Null[int]()})
+	Equal(t, test.Map, map[string]Optional[int]{"a": Some(3), "b": Null[int]()})
+
+	values = url.Values{
+		"Name": []string{""},
+		"Age":  []string{"abc"},
+		"Nick": []string{"null"},
+	}
+
+	decoder.SetNullToken("null")
+
+	test = TestStruct{Missing: Some(1)}
+
+	errs = decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+	Equal(t, errs.Error(), "Field Namespace:Age ERROR:Invalid Integer Value 'abc' Type 'int' Namespace 'Age'")
+	Equal(t, test.Name, Some(""))
+	Equal(t, test.Age, Optional[int]{})
+	Equal(t, test.Nick, Null[string]())
+	Equal(t, test.Missing, Some(1))
+}
+
+func TestDecoderSQLNull(t *testing.T) {
+
+	type TestStruct struct {
+		String  sql.NullString
+		Int     sql.NullInt64
+		Empty   sql.NullInt64
+		Null    sql.NullString
+		Missing sql.NullBool
+		Time    sql.NullTime
+		Generic sql.Null[uint]
+		Strings []sql.NullString
+		Map     map[string]sql.NullFloat64
+	}
+
+	values := url.Values{
+		"String":     []string{"Joey"},
+		"Int":        []string{"3"},
+		"Empty":      []string{""},
+		"Null":       []string{"null"},
+		"Time":       []string{"2016-01-02T00:00:00Z"},
+		"Generic":    []string{"4"},
+		"Strings[0]": []string{"a"},
+		"Strings[1]": []string{""},
+		"Map[pi]":    []string{"3.14"},
+	}
+
+	test := TestStruct{
+		Empty:   sql.NullInt64{Int64: 1, Valid: true},
+		Missing: sql.NullBool{Bool: true, Valid: true},
+	}
+
+	decoder := NewDecoder()
+	decoder.SetNullToken("null")
+
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.String, sql.NullString{String: "Joey", Valid: true})
+	Equal(t, test.Int, sql.NullInt64{Int64: 3, Valid: true})
+	Equal(t, test.Empty, sql.NullInt64{})
+	Equal(t, test.Null, sql.NullString{})
+	Equal(t, test.Missing, sql.NullBool{Bool: true, Valid: true})
+	Equal(t, test.Time, sql.NullTime{Time: time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC), Valid: true})
+	Equal(t, test.Generic, sql.Null[uint]{V: 4, Valid: true})
+	Equal(t, test.Strings, []sql.NullString{{String: "a", Valid: true}, {}})
+	Equal(t, test.Map, map[string]sql.NullFloat64{"pi": {Float64: 3.14, Valid: true}})
+
+	decoder.SetNullPolicy(NullEmptyValid)
+
+	test = TestStruct{}
+
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Empty, sql.NullInt64{Valid: true})
+	Equal(t, test.Null, sql.NullString{})
+	Equal(t, test.Strings, []sql.NullString{{String: "a", Valid: true}, {Valid: true}})
+
+	decoder = NewDecoder()
+	decoder.RegisterCustomTypeFunc(func(vals []string) (interface{}, error) {
+		return sql.NullString{String: "custom", Valid: true}, nil
+	}, sql.NullString{})
+
+	test = TestStruct{}
+
+	errs = decoder.Decode(&test, url.Values{"String": []string{""}, "Int": []string{"abc"}})
+	NotEqual(t, errs, nil)
+	Equal(t, errs.Error(), "Field Namespace:Int ERROR:Invalid Integer Value 'abc' Type 'int64' Namespace 'Int'")
+	Equal(t, test.String, sql.NullString{String: "custom", Valid: true})
+	Equal(t, test.Int, sql.NullInt64{})
+}
+
+type hookPhone struct {
+	Number string
+	Valid  bool
+}
+
+func (p *hookPhone) AfterFormDecode() error {
+	p.Number = strings.Replace(p.Number, "-", "", -1)
+	return nil
+}
+
+func (p hookPhone) Validate() error {
+	if len(p.Number) != 10 {
+		return errors.New("invalid phone number")
+	}
+	return nil
+}
+
+// HookPhone is exported so that its promoted hooks can be tested on an exported embedded struct.
+type HookPhone struct {
+	hookPhone
+}
+
+type hookUser struct {
+	Name   string
+	Home   hookPhone
+	Work   *hookPhone
+	Other  hookPhone
+	Phones []hookPhone
+}
+
+func (u *hookUser) Validate() error {
+	if len(u.Name) == 0 {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func TestDecoderHooks(t *testing.T) {
+
+	values := url.Values{
+		"Name":             []string{"Joey"},
+		"Home.Number":      []string{"111-111-1111"},
+		"Work.Number":      []string{"222-222-222"},
+		"Phones[0].Number": []string{"333-333-3333"},
+		"Phones[1].Number": []string{"444"},
+	}
+
+	var test hookUser
+
+	decoder := NewDecoder()
+
+	errs := decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+
+	de := errs.(DecodeErrors)
+	Equal(t, len(de), 2)
+	Equal(t, de["Work"].Error(), "invalid phone number")
+	Equal(t, de["Phones[1]"].Error(), "invalid phone number")
+	Equal(t, test.Home.Number, "1111111111")
+	Equal(t, test.Work.Number, "222222222")
+	Equal(t, test.Other.Number, "")
+	Equal(t, test.Phones[0].Number, "3333333333")
+
+	test = hookUser{}
+
+	errs = decoder.Decode(&test, url.Values{})
+	NotEqual(t, errs, nil)
+	Equal(t, errs.Error(), "Field Namespace: ERROR:name is required")
+
+	// the hooks of embedded structs are promoted to, and only called on, their parent
+	var embedded struct {
+		hookPhone
+	}
+
+	errs = decoder.Decode(&embedded, url.Values{"hookPhone.Number": []string{"111-111-1111"}})
+	Equal(t, errs, nil)
+	Equal(t, embedded.Number, "1111111111")
+
+	var exported struct {
+		HookPhone
+	}
+
+	errs = decoder.Decode(&exported, url.Values{"HookPhone.hookPhone.Number": []string{"111-111"}})
+	NotEqual(t, errs, nil)
+
+	de = errs.(DecodeErrors)
+	Equal(t, len(de), 1)
+	Equal(t, de[""].Error(), "invalid phone number")
+	Equal(t, exported.Number, "111111")
+}
+
+func TestDecoderContextTypeFunc(t *testing.T) {
+
+	type ctxKey struct{}
+
+	type TestStruct struct {
+		Start time.Time   `form:"start,layout=date"`
+		Times []time.Time `form:"times,layout=time"`
+		Map   map[time.Time]int
+	}
+
+	var calls []FieldContext
+
+	decoder := NewDecoder()
+	decoder.RegisterContextTypeFunc(func(fc FieldContext, vals []string) (interface{}, error) {
+
+		calls = append(calls, fc)
+
+		layout := time.RFC3339
+
+		for _, o := range fc.Options {
+			switch o {
+			case "layout=date":
+				layout = "2006-01-02"
+			case "layout=time":
+				layout = "15:04"
+			}
+		}
+
+		loc, ok := fc.Context.Value(ctxKey{}).(*time.Location)
+		if !ok {
+			loc = time.UTC
+		}
+
+		return time.ParseInLocation(layout, vals[0], loc)
+	}, time.Time{})
+
+	values := url.Values{
+		"start":                     []string{"2016-01-02"},
+		"times[0]":                  []string{"10:30"},
+		"Map[2016-01-02T00:00:00Z]": []string{"1"},
+	}
+
+	loc := time.FixedZone("test", 3600)
+	ctx := context.WithValue(context.Background(), ctxKey{}, loc)
+
+	var test TestStruct
+
+	errs := decoder.DecodeContext(ctx, &test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Start, time.Date(2016, 1, 2, 0, 0, 0, 0, loc))
+	Equal(t, test.Times, []time.Time{time.Date(0, 1, 1, 10, 30, 0, 0, loc)})
+	Equal(t, len(test.Map), 1)
+	Equal(t, len(calls), 3)
+	Equal(t, calls[0].Namespace, "start")
+	Equal(t, calls[0].Field.Name, "Start")
+	Equal(t, calls[0].Options, []string{"layout=date"})
+	Equal(t, calls[1].Namespace, "times[0]")
+	Equal(t, calls[1].Field.Name, "Times")
+	Equal(t, calls[2].Field.Name, "Map")
+
+	calls = nil
+
+	errs = decoder.Decode(&test, url.Values{"start": []string{"2016-01-02"}})
+	Equal(t, errs, nil)
+	Equal(t, test.Start, time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC))
+	Equal(t, len(calls), 1)
+	Equal(t, calls[0].Context, context.Background())
+}
+
+type testIDer interface {
+	ID() string
+}
+
+type testUserID int
+
+func (u *testUserID) ID() string { return "u" + strconv.Itoa(int(*u)) }
+
+type testOrderID int
+
+func (o testOrderID) ID() string { return "o" + strconv.Itoa(int(o)) }
+
+type testColor string
+
+func TestDecoderMatchedTypeFunc(t *testing.T) {
+
+	type TestStruct struct {
+		User     testUserID
+		UserPtr  *testUserID
+		Orders   []testOrderID
+		Color    testColor
+		Name     string
+		Exact    testOrderID
+		ColorMap map[testColor]int
+	}
+
+	parseID := func(prefix string) func(string) (int, error) {
+		return func(s string) (int, error) {
+			if !strings.HasPrefix(s, prefix) {
+				return 0, errors.New("invalid id")
+			}
+			return strconv.Atoi(s[len(prefix):])
+		}
+	}
+
+	var matched []reflect.Type
+
+	decoder := NewDecoder()
+	decoder.RegisterMatchedTypeFunc(func(vals []string) (interface{}, error) {
+		return testColor(strings.ToUpper(vals[0])), nil
+	}, func(typ reflect.Type) bool {
+		matched = append(matched, typ)
+		return typ == reflect.TypeOf(testColor(""))
+	})
+	decoder.RegisterMatchedContextTypeFunc(func(fc FieldContext, vals []string) (interface{}, error) {
+
+		if fc.Field.Type.Kind() == reflect.Slice {
+			id, err := parseID("o")(vals[0])
+			return testOrderID(id), err
+		}
+
+		id, err := parseID("u")(vals[0])
+		return testUserID(id), err
+	}, MatchInterface((*testIDer)(nil)))
+	decoder.RegisterMatchedTypeFunc(func(vals []string) (interface{}, error) {
+		return nil, errors.New("never called")
+	}, MatchKind(reflect.String, reflect.Bool))
+	decoder.RegisterCustomTypeFunc(func(vals []string) (interface{}, error) {
+		return testOrderID(42), nil
+	}, testOrderID(0))
+
+	values := url.Values{
+		"User":            []string{"u1"},
+		"UserPtr":         []string{"u2"},
+		"Orders":          []string{"o3", "o4"},
+		"Color":           []string{"red"},
+		"Exact":           []string{"o5"},
+		"ColorMap[green]": []string{"1"},
+	}
+
+	var test TestStruct
+
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.User, testUserID(1))
+	Equal(t, *test.UserPtr, testUserID(2))
+	Equal(t, test.Orders, []testOrderID{42, 42})
+	Equal(t, test.Color, testColor("RED"))
+	Equal(t, test.Exact, testOrderID(42))
+	Equal(t, test.ColorMap, map[testColor]int{"GREEN": 1})
+
+	count := len(matched)
+
+	test = TestStruct{}
+
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, len(matched), count)
+
+	errs = decoder.Decode(&test, url.Values{"Name": []string{"Joey"}, "User": []string{"x1"}})
+	NotEqual(t, errs, nil)
+
+	de := errs.(DecodeErrors)
+	Equal(t, len(de), 2)
+	Equal(t, de["Name"].Error(), "never called")
+	Equal(t, de["User"].Error(), "invalid id")
+
+	PanicMatches(t, func() { MatchInterface(testOrderID(0)) }, "MatchInterface requires a nil pointer to an interface eg. (*fmt.Stringer)(nil)")
+}
+
+func TestDecoderGenericTypeFunc(t *testing.T) {
+
+	type TestStruct struct {
+		Color    testColor
+		Colors   []testColor
+		ColorMap map[testColor]int
+		Order    testOrderID
+		OrderPtr *testOrderID
+		Count    int
+		Ptr      *int
+	}
+
+	decoder := NewDecoder()
+	RegisterDecodeFunc(decoder, func(vals []string) (testColor, error) {
+		return testColor(strings.ToUpper(vals[0])), nil
+	})
+
+	values := url.Values{
+		"Color":           []string{"red"},
+		"Colors":          []string{"green", "blue"},
+		"ColorMap[black]": []string{"1"},
+	}
+
+	var test TestStruct
+
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Color, testColor("RED"))
+	Equal(t, test.Colors, []testColor{"GREEN", "BLUE"})
+	Equal(t, test.ColorMap, map[testColor]int{"BLACK": 1})
+
+	// the legacy funcs return errors rather than panicking on the wrong type or nil
+	decoder = NewDecoder()
+	decoder.RegisterCustomTypeFunc(func(vals []string) (interface{}, error) {
+		return vals[0], nil
+	}, testColor(""), testOrderID(0))
+	decoder.RegisterCustomTypeFunc(func(vals []string) (interface{}, error) {
+		return nil, nil
+	}, 0, (*int)(nil))
+
+	values = url.Values{
+		"Color":          []string{"red"},
+		"ColorMap[blue]": []string{"1"},
+		"Order":          []string{"1"},
+		"OrderPtr":       []string{"2"},
+		"Count":          []string{"3"},
+		"Ptr":            []string{"4"},
+	}
+
+	test = TestStruct{}
+
+	errs = decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+
+	de := errs.(DecodeErrors)
+	Equal(t, len(de), 5)
+	Equal(t, de["Color"].Error(), "Custom type func returned type 'string' which can't be assigned to type 'form.testColor'")
+	Equal(t, de["ColorMap"].Error(), "Custom type func returned type 'string' which can't be assigned to type 'form.testColor'")
+	Equal(t, de["Order"].Error(), "Custom type func returned type 'string' which can't be assigned to type 'form.testOrderID'")
+	Equal(t, de["OrderPtr"].Error(), "Custom type func returned type 'string' which can't be assigned to type 'form.testOrderID'")
+	Equal(t, de["Count"].Error(), "Custom type func returned nil for type 'int' which can't be nil")
+	Equal(t, test.Ptr, nil)
+}
+
+type testShape interface {
+	Area() float64
+}
+
+type testCircle struct {
+	Radius float64
+}
+
+func (c testCircle) Area() float64 { return 3 * c.Radius * c.Radius }
+
+type testSquare struct {
+	Side float64
+}
+
+func (s *testSquare) Area() float64 { return s.Side * s.Side }
+
+func TestDecoderNamedTypes(t *testing.T) {
+
+	type TestStruct struct {
+		Shape   testShape
+		Shapes  []testShape
+		ShapeMp map[string]testShape
+		Any     interface{}
+		Unknown testShape
+		Invalid testShape
+		Missing testShape
+	}
+
+	decoder := NewDecoder()
+	decoder.RegisterType("circle", testCircle{})
+	decoder.RegisterType("square", &testSquare{})
+	decoder.RegisterType("string", "")
+
+	values := url.Values{
+		"Shape.type":        []string{"circle"},
+		"Shape.Radius":      []string{"3"},
+		"Shapes[0].type":    []string{"square"},
+		"Shapes[0].Side":    []string{"2"},
+		"Shapes[1].type":    []string{"square"},
+		"ShapeMp[a].type":   []string{"circle"},
+		"ShapeMp[a].Radius": []string{"1"},
+		"Any.type":          []string{"string"},
+		"Any":               []string{"value"},
+		"Unknown.type":      []string{"triangle"},
+		"Invalid.type":      []string{"string"},
+	}
+
+	var test TestStruct
+
+	errs := decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+
+	de := errs.(DecodeErrors)
+	Equal(t, len(de), 2)
+	Equal(t, de["Unknown.type"].Error(), "Type name 'triangle' is not registered on the decoder")
+	Equal(t, de["Invalid.type"].Error(), "Type 'string' registered as 'string' can't be assigned to type 'form.testShape'")
+
+	Equal(t, test.Shape, testCircle{Radius: 3})
+	Equal(t, len(test.Shapes), 2)
+	Equal(t, test.Shapes[0], &testSquare{Side: 2})
+	Equal(t, test.Shapes[1], &testSquare{})
+	Equal(t, test.ShapeMp["a"], testCircle{Radius: 1})
+	Equal(t, test.Any, "value")
+	Equal(t, test.Unknown, nil)
+	Equal(t, test.Missing, nil)
+
+	decoder.SetDiscriminatorKey("kind")
+
+	test = TestStruct{}
+
+	errs = decoder.Decode(&test, url.Values{"Shape.kind": []string{"circle"}, "Shape.Radius": []string{"2"}})
+	Equal(t, errs, nil)
+	Equal(t, test.Shape, testCircle{Radius: 2})
+}
+
+func TestDecoderDynamic(t *testing.T) {
+
+	type TestStruct struct {
+		Any     interface{}
+		Map     map[string]interface{}
+		Leaf    interface{}
+		Missing interface{}
+	}
+
+	values := url.Values{
+		"Any.Name":          []string{"Joey"},
+		"Any.Tags[]":        []string{"a"},
+		"Any.Phones[0].Num": []string{"1"},
+		"Any.Phones[2].Num": []string{"3"},
+		"Any.Sparse[a]":     []string{"x"},
+		"Any.Sparse[1]":     []string{"y"},
+		"Any.Multi":         []string{"1", "2"},
+		"Map[a][b]":         []string{"c"},
+		"Map[0]":            []string{"zero"},
+		"Map.d":             []string{"e"},
+		"Leaf":              []string{"value"},
+		"Leaf.Child":        []string{"child"},
+	}
+
+	var test TestStruct
+
+	decoder := NewDecoder()
+
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Any, map[string]interface{}{
+		"Name":   "Joey",
+		"Tags":   []string{"a"},
+		"Phones": []interface{}{map[string]interface{}{"Num": "1"}, nil, map[string]interface{}{"Num": "3"}},
+		"Sparse": map[string]interface{}{"a": "x", "1": "y"},
+		"Multi":  []string{"1", "2"},
+	})
+	Equal(t, test.Map, map[string]interface{}{
+		"a": map[string]interface{}{"b": "c"},
+		"0": "zero",
+		"d": "e",
+	})
+	Equal(t, test.Leaf, map[string]interface{}{"Child": "child"})
+	Equal(t, test.Missing, nil)
+
+	var mp map[string]interface{}
+
+	errs = decoder.Decode(&mp, url.Values{"a": []string{"1"}, "b[0]": []string{"2"}, "b[1]": []string{"3"}})
+	Equal(t, errs, nil)
+	Equal(t, mp, map[string]interface{}{"a": "1", "b": []interface{}{"2", "3"}})
+
+	var iface interface{}
+
+	errs = decoder.Decode(&iface, url.Values{"[0]": []string{"1"}, "[1]": []string{"2"}})
+	Equal(t, errs, nil)
+	Equal(t, iface, []interface{}{"1", "2"})
+
+	decoder.SetMaxArraySize(2)
+
+	iface = nil
+
+	errs = decoder.Decode(&iface, url.Values{"[0]": []string{"1"}, "[2]": []string{"2"}})
+	Equal(t, errs, nil)
+	Equal(t, iface, map[string]interface{}{"0": "1", "2": "2"})
+
+	decoder.RegisterType("circle", testCircle{})
+
+	test = TestStruct{}
+
+	errs = decoder.Decode(&test, url.Values{"Any.type": []string{"webhook"}, "Any.id": []string{"1"}})
+	Equal(t, errs, nil)
+	Equal(t, test.Any, map[string]interface{}{"type": "webhook", "id": "1"})
+
+	// the same sparse and map entry limits apply as to typed slices and maps
+	sparse := url.Values{"Any.x[3]": []string{"1"}, "Any.x[9999]": []string{"2"}}
+
+	decoder = NewDecoder()
+	decoder.SetSparsePolicy(SparseCompact, 0)
+
+	test = TestStruct{}
+
+	errs = decoder.Decode(&test, sparse)
+	Equal(t, errs, nil)
+	Equal(t, test.Any, map[string]interface{}{"x": []interface{}{"1", "2"}})
+
+	decoder.SetSparsePolicy(SparseReject, 2)
+
+	test = TestStruct{}
+
+	errs = decoder.Decode(&test, sparse)
+	NotEqual(t, errs, nil)
+	Equal(t, errs.(DecodeErrors)["Any.x"].Error(), "Array size of '10000' is too sparse for the '2' elements provided. To change this policy please see, SetSparsePolicy(policy SparsePolicy, factor uint)")
+	Equal(t, test.Any, map[string]interface{}{"x": nil})
+
+	decoder = NewDecoder()
+	decoder.SetLimits(Limits{MaxMapEntries: 3})
+
+	test = TestStruct{}
+
+	errs = decoder.Decode(&test, url.Values{"Map[a][b]": []string{"1"}, "Map[a][c]": []string{"2"}, "Map[d]": []string{"3"}})
+	NotEqual(t, errs, nil)
+	Equal(t, errs.(DecodeErrors)["Map[a]"].Error(), "Limit MaxMapEntries of '3' exceeded with '4'")
+	Equal(t, test.Map, map[string]interface{}{"d": "3"})
+}
+
+type testAge uint8
+
+func TestDecoderScalarPlans(t *testing.T) {
+
+	type Nested struct {
+		Age testAge
+	}
+
+	type TestStruct struct {
+		String  string
+		Bool    bool
+		Int8    int8
+		Uint16  uint16
+		Float32 float32
+		Age     testAge
+		Nested  Nested
+		Empty   int
+	}
+
+	values := url.Values{
+		"String":     []string{"str"},
+		"Bool":       []string{"on"},
+		"Int8":       []string{"-8"},
+		"Uint16":     []string{"16"},
+		"Float32":    []string{"3.2"},
+		"Age":        []string{"33"},
+		"Nested.Age": []string{"44"},
+		"Empty":      []string{""},
+	}
+
+	decoder := NewDecoder()
+
+	var test TestStruct
+
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test, TestStruct{String: "str", Bool: true, Int8: -8, Uint16: 16, Float32: 3.2, Age: 33, Nested: Nested{Age: 44}})
+
+	// errors are the same as those of the reflective path, and aren't shared between calls
+	invalid := url.Values{"Int8": []string{"128"}, "Bool": []string{"x"}, "Nested.Age": []string{"256"}}
+
+	errs = decoder.Decode(&test, invalid)
+	NotEqual(t, errs, nil)
+
+	other := decoder.Decode(&test, url.Values{"Uint16": []string{"-1"}})
+	NotEqual(t, other, nil)
+
+	e := errs.(DecodeErrors)
+	Equal(t, len(e), 3)
+	Equal(t, e["Int8"].Error(), "Invalid Integer Value '128' Type 'int8' Namespace 'Int8'")
+	Equal(t, e["Bool"].Error(), "Invalid Boolean Value 'x' Type 'bool' Namespace 'Bool'")
+	Equal(t, e["Nested.Age"].Error(), "Invalid Unsigned Integer Value '256' Type 'form.testAge' Namespace 'Nested.Age'")
+	Equal(t, len(other.(DecodeErrors)), 1)
+
+	// custom type funcs registered after the plans are compiled take precedence
+	decoder.RegisterCustomTypeFunc(func(vals []string) (interface{}, error) {
+		return testAge(len(vals[0])), nil
+	}, testAge(0))
+
+	test = TestStruct{}
+
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Age, testAge(2))
+	Equal(t, test.Nested.Age, testAge(2))
+	Equal(t, test.Int8, int8(-8))
+}
+
+func TestDecoderDataMapReuse(t *testing.T) {
+
+	type TestStruct struct {
+		Map   map[string]map[string]int
+		Slice []int
+	}
+
+	decoder := NewDecoder()
+
+	values := url.Values{"Slice[1]": []string{"2"}}
+
+	for i := 0; i < 100; i++ {
+		values["Map[k"+strconv.Itoa(i)+"][v]"] = []string{strconv.Itoa(i)}
+	}
+
+	var test TestStruct
+
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, len(test.Map), 100)
+	Equal(t, test.Map["k99"]["v"], 99)
+	Equal(t, test.Slice, []int{0, 2})
+
+	// the pooled data of the previous call must not leak into this one
+	test = TestStruct{}
+
+	errs = decoder.Decode(&test, url.Values{"Map[a][b]": []string{"1"}})
+	Equal(t, errs, nil)
+	Equal(t, test.Map, map[string]map[string]int{"a": {"b": 1}})
+	Equal(t, len(test.Slice), 0)
+}
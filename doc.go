@@ -214,6 +214,34 @@ Encoder
             return []string{x.(time.Time).Format("2006-01-02")}, nil
         }, time.Time{})
 
+or type safe using the generic helpers, a CustomTypeFunc returning nil or the
+wrong type is reported as an error
+
+    form.RegisterDecodeFunc(decoder, func(vals []string) (time.Time, error) {
+        return time.Parse("2006-01-02", vals[0])
+    })
+
+    form.RegisterEncodeFunc(encoder, func(t time.Time) ([]string, error) {
+        return []string{t.Format("2006-01-02")}, nil
+    })
+
+Context aware funcs also receive the context passed to DecodeContext or
+EncodeContext, the value's namespace and the struct field with its tag options
+
+    decoder.RegisterContextTypeFunc(func(fc form.FieldContext, vals []string) (interface{}, error) {
+            loc := fc.Context.Value(locationKey{}).(*time.Location)
+            return time.ParseInLocation("2006-01-02", vals[0], loc)
+        }, time.Time{})
+
+    err := decoder.DecodeContext(ctx, &user, values)
+
+funcs can also be registered for every type implementing an interface, of a
+kind or matching any TypeMatcher, resolved once per type; a type implementing
+the interface on its pointer is passed to encode funcs as a pointer
+
+    decoder.RegisterMatchedTypeFunc(parseID, form.MatchInterface((*IDer)(nil)))
+    encoder.RegisterMatchedTypeFunc(formatEnum, form.MatchKind(reflect.Uint8))
+
 
 Ignoring Fields
 
@@ -223,6 +251,167 @@ you can tell form to ignore fields using `-` in the tag
         Field string `form:"-"`
     }
 
+Aliases
+
+a field can be given multiple names separated by `|`, the Encoder always
+uses the first, the Decoder accepts any of them; see SetAliasPolicy for
+when more than one is present
+
+    type MyStruct struct {
+        Query string `form:"query|q"`
+    }
+
+Omitting Empty Fields
+
+the Encoder skips fields with the `omitempty` option when they hold their
+empty value, as defined by encoding/json
+
+    type MyStruct struct {
+        Field string `form:"field,omitempty"`
+    }
+
+existing json tags can be used for fields without a form tag by setting
+an ordered list of tag names
+
+    encoder.SetTagNames("form", "json")
+
+Collection Sizes
+
+the number of elements decoded into a slice, array or map can be limited
+per field, any values outside the limits are reported in DecodeErrors
+before anything is allocated; min is only checked when the field has values
+
+    type MyStruct struct {
+        Tags []string `form:"tags,max=20"`
+        IDs  []int    `form:"ids,min=1,max=100"`
+    }
+
+Naming Fields
+
+fields without a tag use the Go field name as is, unless a NamingFunc
+is set; SnakeCase, CamelCase, KebabCase and LowerCase are provided
+
+    decoder.SetNamingFunc(form.SnakeCase)
+
+    type MyStruct struct {
+        FirstName string                  // first_name
+        LastName  string `form:"surname"` // surname
+    }
+
+Slice Styles
+
+the Encoder can write slices repeated, indexed, with empty brackets or
+delimited, for every slice using SetSliceStyle or per field using the
+"slice" or "delim" tag options
+
+    type MyStruct struct {
+        IDs  []int    `form:"ids,slice=brackets"` // ids[]=1&ids[]=2
+        Tags []string `form:"tags,delim=,"`       // tags=a,b
+        Pipe []string `form:"pipe,delim=pipe"`    // pipe=a|b
+    }
+
+the Decoder splits the values of fields with the "delim" tag option, or of
+all slices using SetSliceDelimiter, adding the "trim" option trims space
+from each part; comma, pipe, space, tab and semicolon can be used by name,
+and reads empty brackets eg. ids[]=1&ids[]=2 as repeated values, so every
+style decodes back into the same slice
+
+Empty and Null Values
+
+by default an empty value sets strings to "" and leaves all other fields
+untouched, SetEmptyPolicy can instead ignore empty values, set the zero value
+or set pointers to nil
+
+    decoder.SetEmptyPolicy(form.EmptyNil) // Age= sets *int to nil
+
+nil pointers are omitted when encoding, SetNullToken on both the Encoder and
+Decoder writes a token for nil pointers and decodes it back to nil
+
+    encoder.SetNullToken("null") // Age=null
+    decoder.SetNullToken("null")
+
+Optional tells apart a field that was absent, explicitly null or set, eg.
+for PATCH requests, and works within slices, maps and nested structs
+
+    type Patch struct {
+        Name form.Optional[string] // Name=Joey is form.Some("Joey")
+        Age  form.Optional[int]    // Age= or Age=null is form.Null[int]()
+    }
+
+database/sql Null types eg. sql.NullString or sql.Null[T] are decoded as Valid
+when a non-empty value is present, SetNullPolicy can decode empty values as
+a Valid zero value instead; invalid values are omitted when encoding, unless
+a null token is set
+
+Interface Fields
+
+concrete types registered under a name on both the Encoder and Decoder are
+written to and read from a discriminator key, "type" by default, allowing nil
+interface fields to be decoded, including within slices and maps
+
+    decoder.RegisterType("circle", Circle{})
+    decoder.RegisterType("square", &Square{})
+
+    // Shape.type=circle&Shape.Radius=3 decodes Shape as Circle{Radius: 3}
+
+Dynamic Values
+
+a nil interface{} or a map[string]interface{} is decoded into a tree of
+map[string]interface{} for names and keys, []interface{} for indexes and a
+string, or []string for multiple values, for leaves; the SparsePolicy and
+MaxMapEntries limit apply as they do to typed slices and maps
+
+    // Meta.tags[]=a&Meta.user.name=Joey&Meta.ids[0]=1 decodes Meta as
+    map[string]interface{}{
+        "tags": []string{"a"},
+        "user": map[string]interface{}{"name": "Joey"},
+        "ids":  []interface{}{"1"},
+    }
+
+Code Generation
+
+cmd/formgen generates DecodeForm and EncodeForm methods for struct types,
+which decode and encode string, bool, integer and float fields without
+reflection, falling back to reflection for all other fields; they are called
+automatically by a Decoder or Encoder using the default tag and naming rules
+
+    //go:generate go run github.com/go-playground/form/cmd/formgen -type User,Address
+
+Options
+
+a Decoder or Encoder can be configured by the options passed to NewDecoder and
+NewEncoder, rather than mutated by its Set and Register methods, and variants
+of one in use derived, with their own caches, using With
+
+    decoder := form.NewDecoder(form.WithTagName("json"), form.WithMaxArraySize(100))
+    strict := decoder.With(form.WithLimits(form.Limits{MaxKeys: 50}))
+
+options which don't change how structs are parsed can also be overridden for
+a single call, sharing the struct cache
+
+    err := decoder.DecodeWithOptions(&bulk, values, form.WithMaxArraySize(50000))
+
+Struct Cache
+
+each struct type is parsed once, on first use, and cached; Warmup parses
+types, and the struct types of their fields, up front and SetDynamicTypeLimit
+bounds the number of unnamed types eg. built with reflect.StructOf, kept
+
+    decoder.Warmup(User{}, Order{})
+    decoder.SetDynamicTypeLimit(1000)
+
+Hooks
+
+structs implementing AfterDecoder or Validator are called once their fields
+have been decoded, nested structs only when at least one of their fields was
+set, and structs implementing BeforeEncoder are called on a copy before being
+encoded; returned errors are reported under the struct's namespace
+
+    func (p *Phone) AfterFormDecode() error {
+        p.Number = strings.TrimSpace(p.Number)
+        return nil
+    }
+
 Notes
 
 To maximize compatibility with other systems the Encoder attempts
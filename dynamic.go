@@ -0,0 +1,284 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// dynamicNode is a node of the tree built from the keys under a namespace
+// when decoding into an empty interface or map[string]interface{}.
+type dynamicNode struct {
+	leaf     interface{}
+	children map[string]*dynamicNode
+	indexes  bool // all children are keyed by a bracketed index eg. [0]
+	bracket  bool // the node is keyed by brackets eg. [a] rather than .a
+	max      int
+}
+
+type pathSegment struct {
+	name    string
+	bracket bool
+}
+
+// isDynamicMap returns whether typ is a map of string keys to empty interfaces.
+func isDynamicMap(typ reflect.Type) bool {
+	return typ.Key().Kind() == reflect.String && typ.Elem().Kind() == reflect.Interface && typ.Elem().NumMethod() == 0
+}
+
+// buildDynamic returns the node of the tree of all keys at namespace, returning nil
+// when there are no keys under it; the tree is built once per decode, on first use,
+// so that every dynamic value, eg. each element of a []interface{}, doesn't scan
+// all of the keys again.
+func (d *decoder) buildDynamic(namespace []byte) *dynamicNode {
+
+	if d.dynamic == nil {
+
+		var segs []pathSegment
+
+		d.dynamic = new(dynamicNode)
+
+		for k, vals := range d.values {
+			d.dynamic.insert(splitPath(k, segs[:0]), vals, d.d.maxArraySize)
+		}
+	}
+
+	n := d.dynamic
+
+	for _, seg := range splitPath(string(namespace), nil) {
+
+		if n = n.children[seg.name]; n == nil {
+			return nil
+		}
+	}
+
+	if n.leaf == nil && len(n.children) == 0 {
+		return nil
+	}
+
+	return n
+}
+
+// insert adds vals to the tree at the path of segs.
+func (n *dynamicNode) insert(segs []pathSegment, vals []string, maxArraySize int) {
+
+	var leaf interface{} = vals
+
+	// empty brackets at the end eg. "ids[]" always hold a list of values
+	if l := len(segs); l > 0 && segs[l-1].bracket && len(segs[l-1].name) == 0 {
+		segs = segs[:l-1]
+	} else if len(vals) == 1 {
+		leaf = vals[0]
+	}
+
+	for _, seg := range segs {
+
+		if n.children == nil {
+			n.children = make(map[string]*dynamicNode)
+			n.indexes = true
+		}
+
+		if n.indexes {
+
+			if idx, err := strconv.Atoi(seg.name); !seg.bracket || err != nil || idx < 0 || idx >= maxArraySize {
+				n.indexes = false
+			} else if idx > n.max {
+				n.max = idx
+			}
+		}
+
+		child, ok := n.children[seg.name]
+		if !ok {
+			child = &dynamicNode{bracket: seg.bracket}
+			n.children[seg.name] = child
+		}
+
+		n = child
+	}
+
+	n.leaf = leaf
+}
+
+// dynamicValue returns node n under namespace as a string or []string leaf, an
+// []interface{} when all of its children are indexes or a map[string]interface{};
+// children take precedence over a leaf. Slices and maps are subject to the same
+// SparsePolicy and MaxMapEntries limit as typed ones, being nil when exceeded.
+func (d *decoder) dynamicValue(n *dynamicNode, namespace []byte) interface{} {
+
+	if len(n.children) == 0 {
+		return n.leaf
+	}
+
+	if n.indexes {
+		return d.dynamicSlice(n, namespace)
+	}
+
+	if max := d.d.limits.MaxMapEntries; max > 0 {
+
+		if d.mapEntries += len(n.children); d.mapEntries > max {
+			d.setError(namespace, &LimitError{Limit: "MaxMapEntries", Max: max, Value: d.mapEntries})
+			return nil
+		}
+	}
+
+	mp := make(map[string]interface{}, len(n.children))
+
+	for k, child := range n.children {
+		mp[k] = d.dynamicValue(child, childNamespace(namespace, k, child.bracket))
+	}
+
+	return mp
+}
+
+// dynamicSlice returns the indexed children of node n as an []interface{}.
+func (d *decoder) dynamicSlice(n *dynamicNode, namespace []byte) interface{} {
+
+	var positions map[int]int
+
+	sl := n.max + 1
+
+	switch d.d.sparsePolicy {
+	case SparseCompact:
+
+		keys := make([]key, 0, len(n.children))
+
+		for k := range n.children {
+			idx, _ := strconv.Atoi(k)
+			keys = append(keys, key{ivalue: idx})
+		}
+
+		positions = compactIndexes(keys)
+		sl = len(positions)
+
+	case SparseReject:
+		if sl > len(n.children)*d.d.sparseFactor {
+			d.setError(namespace, fmt.Errorf(errSparseArray, sl, len(n.children)))
+			return nil
+		}
+	}
+
+	arr := make([]interface{}, sl)
+
+	for k, child := range n.children {
+
+		idx, _ := strconv.Atoi(k)
+
+		if positions != nil {
+			idx = positions[idx]
+		}
+
+		arr[idx] = d.dynamicValue(child, childNamespace(namespace, k, true))
+	}
+
+	return arr
+}
+
+// childNamespace returns the namespace of the child k of a node under namespace,
+// without modifying namespace.
+func childNamespace(namespace []byte, k string, bracket bool) []byte {
+
+	ns := make([]byte, 0, len(namespace)+len(k)+2)
+	ns = append(ns, namespace...)
+
+	if bracket {
+		ns = append(ns, '[')
+		ns = append(ns, k...)
+		return append(ns, ']')
+	}
+
+	if len(ns) > 0 {
+		ns = append(ns, namespaceSeparator)
+	}
+
+	return append(ns, k...)
+}
+
+// splitPath splits the remainder of a key after its namespace into its field
+// names and bracketed keys eg. ".a[0][b]" into a, 0 and b.
+func splitPath(rest string, segs []pathSegment) []pathSegment {
+
+	var end int
+
+	for len(rest) > 0 {
+
+		switch rest[0] {
+		case namespaceSeparator:
+			rest = rest[1:]
+
+		case '[':
+
+			if end = strings.IndexByte(rest, ']'); end == -1 {
+				return append(segs, pathSegment{name: rest[1:]})
+			}
+
+			segs = append(segs, pathSegment{name: rest[1:end], bracket: true})
+			rest = rest[end+1:]
+
+		default:
+
+			if end = strings.IndexAny(rest, ".["); end == -1 {
+				end = len(rest)
+			}
+
+			segs = append(segs, pathSegment{name: rest[:end]})
+			rest = rest[end:]
+		}
+	}
+
+	return segs
+}
+
+// setDynamic sets the empty interface v to the tree of values under namespace.
+func (d *decoder) setDynamic(v reflect.Value, namespace []byte) (set bool) {
+
+	n := d.buildDynamic(namespace)
+	if n == nil {
+		return
+	}
+
+	if val := d.dynamicValue(n, namespace); val != nil {
+		v.Set(reflect.ValueOf(val))
+		set = true
+	}
+
+	return
+}
+
+// setDynamicMap sets the entries of map v, of string keys to empty interfaces,
+// to the tree of values under namespace.
+func (d *decoder) setDynamicMap(v reflect.Value, namespace []byte, f *cachedField) (set bool) {
+
+	n := d.buildDynamic(namespace)
+	if n == nil || len(n.children) == 0 {
+		return
+	}
+
+	if f != nil && (f.max > 0 || f.min > 0) && !d.checkFieldSize(namespace, f, len(n.children)) {
+		return
+	}
+
+	if max := d.d.limits.MaxMapEntries; max > 0 {
+
+		if d.mapEntries += len(n.children); d.mapEntries > max {
+			d.setError(namespace, &LimitError{Limit: "MaxMapEntries", Max: max, Value: d.mapEntries})
+			return
+		}
+	}
+
+	typ := v.Type()
+
+	if v.IsNil() || d.mode == ModeReplace {
+		v.Set(reflect.MakeMapWithSize(typ, len(n.children)))
+	}
+
+	for k, child := range n.children {
+
+		if val := d.dynamicValue(child, childNamespace(namespace, k, child.bracket)); val != nil {
+			v.SetMapIndex(reflect.ValueOf(k).Convert(typ.Key()), reflect.ValueOf(val))
+			set = true
+		}
+	}
+
+	return
+}
@@ -1,17 +1,40 @@
 package form
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"reflect"
 	"strconv"
+	"sync"
 	"time"
+	"unsafe"
+)
+
+const (
+	errCustomTypeNoValues = "Custom type func returned no values for map key of type '%s'"
 )
 
 type encoder struct {
 	e      *Encoder
+	ctx    context.Context
+	field  *cachedField
+	plain  bool
+	gen    GenEncoder
 	errs   EncodeErrors
 	values url.Values
+	buf    []byte // namespace buffer, kept when the encoder is pooled
+}
+
+var encoderPool = sync.Pool{New: func() interface{} {
+	return &encoder{buf: make([]byte, 0, 64)}
+}}
+
+// release returns e to the pool once the values and errors it references
+// have been returned, keeping only its namespace buffer.
+func (e *encoder) release() {
+	*e = encoder{buf: e.buf[:0]}
+	encoderPool.Put(e)
 }
 
 func (e *encoder) setError(namespace []byte, err error) {
@@ -37,34 +60,159 @@ func (e *encoder) setVal(namespace []byte, idx int, vals ...string) {
 func (e *encoder) traverseStruct(v reflect.Value, namespace []byte, idx int) {
 
 	typ := v.Type()
-	l := len(namespace)
-	first := l == 0
 
 	// anonymous structs will still work for caching as the whole definition is stored
 	// including tags
 	s, ok := e.e.structCache.Get(typ)
 	if !ok {
-		s = e.e.structCache.parseStruct(typ, e.e.tagName)
+		s = e.e.structCache.parseStruct(typ, e.e.tagNames, e.e.naming, FoldNone, e.e.isCustomType)
 	}
 
-	for _, f := range s.fields {
+	// the hook of an embedded struct is called on its parent, and an unexported one can't be copied
+	if s.beforeEncode && (e.field == nil || !e.field.promoted) && v.CanInterface() {
+
+		cp := reflect.New(typ)
+		cp.Elem().Set(v)
+
+		if err := cp.Interface().(BeforeEncoder).BeforeFormEncode(); err != nil {
+			e.setError(namespace, err)
+			return
+		}
+
+		v = cp.Elem()
+	}
 
-		namespace = namespace[:l]
+	if s.genEncode && e.plain {
 
-		if first {
-			namespace = append(namespace, f.name...)
-		} else {
-			namespace = append(namespace, namespaceSeparator)
-			namespace = append(namespace, f.name...)
+		if !v.CanAddr() {
+			cp := reflect.New(typ).Elem()
+			cp.Set(v)
+			v = cp
 		}
 
-		e.setFieldByType(v.Field(f.idx), namespace, idx)
+		g := e.genEncoder()
+		prevIdx, prevStruct := g.idx, g.s
+		g.idx, g.s = idx, s
+
+		v.Addr().Interface().(FormEncoder).EncodeForm(g, string(namespace))
+
+		g.idx, g.s = prevIdx, prevStruct
+
+		return
 	}
 
+	prev := e.field
+
+	for i := 0; i < len(s.fields); i++ {
+		e.setStructField(v, namespace, idx, &s.fields[i])
+	}
+
+	e.field = prev
+
 	return
 }
 
-func (e *encoder) setFieldByType(current reflect.Value, namespace []byte, idx int) {
+// setStructField sets the values for field f of struct v, under the struct's
+// namespace; the caller is responsible for restoring e.field.
+func (e *encoder) setStructField(v reflect.Value, namespace []byte, idx int, f *cachedField) {
+
+	e.field = f
+	fv := v.Field(f.idx)
+
+	if f.omitEmpty && isEmptyValue(fv) {
+		return
+	}
+
+	if f.scalar != nil && fv.CanAddr() {
+		val := f.scalar.encode(unsafe.Pointer(fv.UnsafeAddr()))
+
+		// a top level field's key is its name, so no key needs building
+		if len(namespace) == 0 {
+			e.values[f.name] = append(e.values[f.name], val)
+			return
+		}
+
+		namespace = append(namespace, namespaceSeparator)
+		namespace = append(namespace, f.name...)
+		e.setVal(namespace, idx, val)
+
+		return
+	}
+
+	if len(namespace) == 0 {
+		namespace = append(namespace, f.name...)
+	} else {
+		namespace = append(namespace, namespaceSeparator)
+		namespace = append(namespace, f.name...)
+	}
+
+	e.setFieldByType(fv, namespace, idx, f)
+}
+
+// setTypeName writes the name typ, or the type it points to, is registered
+// under to the discriminator key of namespace, or the element idx of it.
+func (e *encoder) setTypeName(typ reflect.Type, namespace []byte, idx int) {
+
+	name, ok := e.e.typeNames[typ]
+
+	if !ok && typ.Kind() == reflect.Ptr {
+		name, ok = e.e.typeNames[typ.Elem()]
+	}
+
+	if !ok {
+		return
+	}
+
+	ns := make([]byte, len(namespace), len(namespace)+len(e.e.typeKey)+8)
+	copy(ns, namespace)
+
+	if idx > -1 {
+		ns = append(ns, '[')
+		ns = strconv.AppendInt(ns, int64(idx), 10)
+		ns = append(ns, ']')
+	}
+
+	if len(ns) > 0 {
+		ns = append(ns, namespaceSeparator)
+	}
+
+	ns = append(ns, e.e.typeKey...)
+
+	e.setVal(ns, -2, name)
+}
+
+// callTypeFunc calls the registered custom type func cf for rv under namespace,
+// or the element idx of it.
+func (e *encoder) callTypeFunc(cf encodeFunc, namespace []byte, idx int, rv reflect.Value) ([]string, error) {
+
+	var v interface{}
+
+	if cf.addr {
+		v = addr(rv).Interface()
+	} else {
+		v = rv.Interface()
+	}
+
+	if cf.fn != nil {
+		return cf.fn(v)
+	}
+
+	fc := FieldContext{Context: e.ctx, Namespace: string(namespace)}
+
+	if idx > -1 {
+		fc.Namespace += "[" + strconv.Itoa(idx) + "]"
+	}
+
+	if e.field != nil {
+		fc.Field, fc.Options = e.field.field, e.field.options
+	}
+
+	return cf.ctx(fc, v)
+}
+
+// setFieldByType sets the values for current under namespace; f is the
+// struct field being set, if any.
+func (e *encoder) setFieldByType(current reflect.Value, namespace []byte, idx int, f *cachedField) {
 
 	if idx > -1 && current.Kind() == reflect.Ptr {
 		namespace = append(namespace, '[')
@@ -73,13 +221,17 @@ func (e *encoder) setFieldByType(current reflect.Value, namespace []byte, idx in
 		idx = -2
 	}
 
+	if e.e.typeNames != nil && current.Kind() == reflect.Interface && !current.IsNil() {
+		e.setTypeName(current.Elem().Type(), namespace, idx)
+	}
+
 	v, kind := ExtractType(current)
 
-	if e.e.customTypeFuncs != nil {
+	if e.e.hasTypeFuncs() {
 
-		if cf, ok := e.e.customTypeFuncs[v.Type()]; ok {
+		if cf, ok := e.e.typeFunc(v.Type()); ok {
 
-			arr, err := cf(v.Interface())
+			arr, err := e.callTypeFunc(cf, namespace, idx, v)
 			if err != nil {
 				e.setError(namespace, err)
 				return
@@ -97,7 +249,13 @@ func (e *encoder) setFieldByType(current reflect.Value, namespace []byte, idx in
 	}
 
 	switch kind {
-	case reflect.Ptr, reflect.Interface, reflect.Invalid:
+	case reflect.Ptr:
+
+		if len(e.e.nullToken) > 0 {
+			e.setVal(namespace, idx, e.e.nullToken)
+		}
+
+	case reflect.Interface, reflect.Invalid:
 		return
 
 	case reflect.String:
@@ -126,10 +284,30 @@ func (e *encoder) setFieldByType(current reflect.Value, namespace []byte, idx in
 
 	case reflect.Slice, reflect.Array:
 
+		style, delim := e.e.sliceStyle, e.e.sliceDelim
+
+		if f != nil && f.style != SliceAuto {
+			if style = f.style; len(f.delim) > 0 {
+				delim = f.delim
+			}
+		}
+
+		if style != SliceAuto {
+
+			if idx > -1 {
+				namespace = append(namespace, '[')
+				namespace = strconv.AppendInt(namespace, int64(idx), 10)
+				namespace = append(namespace, ']')
+			}
+
+			e.setSliceByStyle(v, namespace, style, delim)
+			return
+		}
+
 		if idx == -1 {
 
 			for i := 0; i < v.Len(); i++ {
-				e.setFieldByType(v.Index(i), namespace, i)
+				e.setFieldByType(v.Index(i), namespace, i, nil)
 			}
 
 			return
@@ -148,7 +326,7 @@ func (e *encoder) setFieldByType(current reflect.Value, namespace []byte, idx in
 			namespace = namespace[:l]
 			namespace = strconv.AppendInt(namespace, int64(i), 10)
 			namespace = append(namespace, ']')
-			e.setFieldByType(v.Index(i), namespace, -2)
+			e.setFieldByType(v.Index(i), namespace, -2, nil)
 		}
 
 	case reflect.Map:
@@ -175,11 +353,57 @@ func (e *encoder) setFieldByType(current reflect.Value, namespace []byte, idx in
 			namespace = append(namespace, s...)
 			namespace = append(namespace, ']')
 
-			e.setFieldByType(current.MapIndex(key), namespace, -2)
+			e.setFieldByType(current.MapIndex(key), namespace, -2, nil)
 		}
 
 	case reflect.Struct:
 
+		if isSQLNull(v.Type()) {
+
+			if !v.Field(1).Bool() && len(e.e.nullToken) == 0 {
+				return
+			}
+
+			// always index elements so invalid ones don't shift those that follow
+			if idx > -1 {
+				namespace = append(namespace, '[')
+				namespace = strconv.AppendInt(namespace, int64(idx), 10)
+				namespace = append(namespace, ']')
+				idx = -2
+			}
+
+			if !v.Field(1).Bool() {
+				e.setVal(namespace, idx, e.e.nullToken)
+				return
+			}
+
+			e.setFieldByType(v.Field(0), namespace, idx, f)
+			return
+		}
+
+		if isOptional(v.Type()) {
+
+			if !v.Field(1).Bool() {
+				return
+			}
+
+			// always index elements so absent ones don't shift those that follow
+			if idx > -1 {
+				namespace = append(namespace, '[')
+				namespace = strconv.AppendInt(namespace, int64(idx), 10)
+				namespace = append(namespace, ']')
+				idx = -2
+			}
+
+			if v.Field(2).Bool() {
+				e.setVal(namespace, idx, e.e.nullToken)
+				return
+			}
+
+			e.setFieldByType(v.Field(0), namespace, idx, f)
+			return
+		}
+
 		// if we get here then no custom time function declared so use RFC3339 by default
 		if v.Type() == timeType {
 
@@ -210,14 +434,106 @@ func (e *encoder) setFieldByType(current reflect.Value, namespace []byte, idx in
 	return
 }
 
+// setSliceByStyle sets the elements of the slice or array v under namespace
+// using style, falling back to indexes when the elements aren't primitives.
+func (e *encoder) setSliceByStyle(v reflect.Value, namespace []byte, style SliceStyle, delim string) {
+
+	if style != SliceIndexed && !e.isPrimitive(v.Type().Elem()) {
+		style = SliceIndexed
+	}
+
+	switch style {
+	case SliceIndexed:
+
+		namespace = append(namespace, '[')
+		l := len(namespace)
+
+		for i := 0; i < v.Len(); i++ {
+			namespace = namespace[:l]
+			namespace = strconv.AppendInt(namespace, int64(i), 10)
+			namespace = append(namespace, ']')
+			e.setFieldByType(v.Index(i), namespace, -2, nil)
+		}
+
+	case SliceDelimited:
+
+		ns := string(namespace)
+		l := len(e.values[ns])
+
+		for i := 0; i < v.Len(); i++ {
+			e.setFieldByType(v.Index(i), namespace, -2, nil)
+		}
+
+		arr := e.values[ns]
+		if len(arr) == l {
+			return
+		}
+
+		buff := make([]byte, 0, 64)
+
+		for i := l; i < len(arr); i++ {
+
+			if i > l {
+				buff = append(buff, delim...)
+			}
+
+			buff = appendEscaped(buff, arr[i], delim)
+		}
+
+		e.values[ns] = append(arr[:l], string(buff))
+
+	default:
+
+		if style == SliceBrackets {
+			namespace = append(namespace, '[', ']')
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			e.setFieldByType(v.Index(i), namespace, -2, nil)
+		}
+	}
+}
+
+// isPrimitive returns if values of typ are written as a single value
+// and so may be written repeated, with brackets or delimited.
+func (e *encoder) isPrimitive(typ reflect.Type) bool {
+
+	for typ.Kind() == reflect.Ptr {
+
+		if _, ok := e.e.customTypeFuncs[typ]; ok {
+			return true
+		}
+
+		typ = typ.Elem()
+	}
+
+	if _, ok := e.e.typeFunc(typ); ok {
+		return true
+	}
+
+	switch typ.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return typ == timeType
+}
+
 func (e *encoder) getMapKey(key reflect.Value, namespace []byte) (string, bool) {
 
 	v, kind := ExtractType(key)
 
-	if e.e.customTypeFuncs != nil {
+	if e.e.hasTypeFuncs() {
+
+		if cf, ok := e.e.typeFunc(v.Type()); ok {
+			arr, err := e.callTypeFunc(cf, namespace, -1, v)
+			if err == nil && len(arr) == 0 {
+				err = fmt.Errorf(errCustomTypeNoValues, v.Type())
+			}
 
-		if cf, ok := e.e.customTypeFuncs[v.Type()]; ok {
-			arr, err := cf(v.Interface())
 			if err != nil {
 				e.setError(namespace, err)
 				return "", false
@@ -1,8 +1,12 @@
 package form
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"net/url"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -1100,3 +1104,581 @@ func TestEncoderPanic(t *testing.T) {
 
 	PanicMatches(t, func() { encoder.Encode(nil) }, "interface must be a struct, pointer to a struct or interface containing one of the aforementioned")
 }
+
+func TestEncoderNamingFunc(t *testing.T) {
+
+	type TestStruct struct {
+		FirstName string
+		LastName  string `form:"surname"`
+		UserID    int
+	}
+
+	test := TestStruct{
+		FirstName: "Joey",
+		LastName:  "Bloggs",
+		UserID:    3,
+	}
+
+	encoder := NewEncoder()
+	encoder.SetNamingFunc(KebabCase)
+
+	values, errs := encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, len(values), 3)
+	Equal(t, values["first-name"], []string{"Joey"})
+	Equal(t, values["surname"], []string{"Bloggs"})
+	Equal(t, values["user-id"], []string{"3"})
+}
+
+func TestEncoderTagNames(t *testing.T) {
+
+	type TestStruct struct {
+		Name     string   `form:"name" json:"full_name"`
+		Email    string   `json:"email,omitempty"`
+		Phone    string   `json:"phone,omitempty"`
+		Age      int      `json:",omitempty"`
+		Ignored  string   `json:"-"`
+		Dash     string   `json:"-,"`
+		FormOnly string   `form:"-" json:"form_only"`
+		Tags     []string `json:"tags,omitempty"`
+		Untagged string
+	}
+
+	test := TestStruct{
+		Name:     "joeybloggs",
+		Phone:    "1(111)111-1111",
+		Ignored:  "ignored",
+		Dash:     "dash",
+		FormOnly: "ignored",
+		Untagged: "untagged",
+	}
+
+	encoder := NewEncoder()
+	encoder.SetTagNames("form", "json")
+
+	values, errs := encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, len(values), 4)
+	Equal(t, values["name"], []string{"joeybloggs"})
+	Equal(t, values["phone"], []string{"1(111)111-1111"})
+	Equal(t, values["-"], []string{"dash"})
+	Equal(t, values["Untagged"], []string{"untagged"})
+
+	encoder.SetTagName("form")
+
+	values, errs = encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, len(values), 7)
+	Equal(t, values["Email"], []string{""})
+	Equal(t, values["Ignored"], []string{"ignored"})
+}
+
+func TestEncoderSliceStyles(t *testing.T) {
+
+	type Item struct {
+		Name string
+		Tags []string
+	}
+
+	type TestStruct struct {
+		IDs       []int
+		Items     []Item
+		Nested    [][]int
+		Map       map[string][]int
+		Indexed   []int    `form:"indexed,slice=indexed"`
+		Brackets  []int    `form:"brackets,slice=brackets"`
+		Repeated  []int    `form:"repeated,slice=repeated"`
+		Comma     []string `form:"comma,delim=,"`
+		Pipe      []string `form:"pipe,delim=pipe,omitempty"`
+		Array     [2]int
+		Times     []time.Time
+		NilPtrs   []*int
+		EmptyList []int
+	}
+
+	tm, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05Z")
+	Equal(t, err, nil)
+
+	i := 3
+
+	test := TestStruct{
+		IDs:       []int{1, 2},
+		Items:     []Item{{Name: "a", Tags: []string{"x", "y"}}},
+		Nested:    [][]int{{1, 2}},
+		Map:       map[string][]int{"key": {1, 2}},
+		Indexed:   []int{1, 2},
+		Brackets:  []int{1, 2},
+		Repeated:  []int{1, 2},
+		Comma:     []string{"a", "b,c", `d\e`},
+		Pipe:      []string{"a", "b"},
+		Array:     [2]int{1, 2},
+		Times:     []time.Time{tm},
+		NilPtrs:   []*int{nil, &i},
+		EmptyList: []int{},
+	}
+
+	encoder := NewEncoder()
+
+	values, errs := encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, len(values), 17)
+	Equal(t, values["IDs"], []string{"1", "2"})
+	Equal(t, values["Items[0].Name"], []string{"a"})
+	Equal(t, values["Items[0].Tags[0]"], []string{"x"})
+	Equal(t, values["Items[0].Tags[1]"], []string{"y"})
+	Equal(t, values["Nested[0][0]"], []string{"1"})
+	Equal(t, values["Nested[0][1]"], []string{"2"})
+	Equal(t, values["Map[key][0]"], []string{"1"})
+	Equal(t, values["Map[key][1]"], []string{"2"})
+	Equal(t, values["indexed[0]"], []string{"1"})
+	Equal(t, values["indexed[1]"], []string{"2"})
+	Equal(t, values["brackets[]"], []string{"1", "2"})
+	Equal(t, values["repeated"], []string{"1", "2"})
+	Equal(t, values["comma"], []string{`a,b\,c,d\\e`})
+	Equal(t, values["pipe"], []string{"a|b"})
+	Equal(t, values["Array"], []string{"1", "2"})
+	Equal(t, values["Times[0]"], []string{"2006-01-02T15:04:05Z"})
+	Equal(t, values["NilPtrs[1]"], []string{"3"})
+
+	encoder.SetSliceStyle(SliceRepeated)
+
+	values, errs = encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, values["IDs"], []string{"1", "2"})
+	Equal(t, values["Items[0].Tags"], []string{"x", "y"})
+	Equal(t, values["Nested[0]"], []string{"1", "2"})
+	Equal(t, values["Map[key]"], []string{"1", "2"})
+	Equal(t, values["indexed[1]"], []string{"2"})
+	Equal(t, values["NilPtrs"], []string{"3"})
+
+	encoder.SetSliceStyle(SliceBrackets)
+
+	values, errs = encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, values["IDs[]"], []string{"1", "2"})
+	Equal(t, values["Items[0].Tags[]"], []string{"x", "y"})
+	Equal(t, values["Nested[0][]"], []string{"1", "2"})
+
+	// the decoder reads empty brackets back as repeated values
+	var decoded TestStruct
+
+	errs = NewDecoder().Decode(&decoded, values)
+	Equal(t, errs, nil)
+	Equal(t, decoded.IDs, test.IDs)
+	Equal(t, decoded.Items, test.Items)
+	Equal(t, decoded.Nested, test.Nested)
+	Equal(t, decoded.Map, test.Map)
+	Equal(t, decoded.Brackets, test.Brackets)
+	Equal(t, decoded.Array, test.Array)
+	Equal(t, decoded.Times, test.Times)
+
+	encoder.SetSliceStyle(SliceIndexed)
+
+	values, errs = encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, values["IDs[0]"], []string{"1"})
+	Equal(t, values["IDs[1]"], []string{"2"})
+	Equal(t, values["repeated"], []string{"1", "2"})
+
+	encoder.SetSliceStyle(SliceDelimited)
+	encoder.SetSliceDelimiter(";")
+
+	values, errs = encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, values["IDs"], []string{"1;2"})
+	Equal(t, values["Items[0].Tags"], []string{"x;y"})
+	Equal(t, values["Nested[0]"], []string{"1;2"})
+	Equal(t, values["comma"], []string{`a,b\,c,d\\e`})
+	Equal(t, values["NilPtrs"], []string{"3"})
+	_, ok := values["EmptyList"]
+	Equal(t, ok, false)
+}
+
+func TestEncoderOptional(t *testing.T) {
+
+	type TestStruct struct {
+		Name    Optional[string]
+		Age     Optional[int]
+		Missing Optional[int]
+		IDs     Optional[[]int]
+		Ints    []Optional[int]
+		Map     map[string]Optional[int]
+	}
+
+	test := TestStruct{
+		Name: Some("Joey"),
+		Age:  Null[int](),
+		IDs:  Some([]int{1, 2}),
+		Ints: []Optional[int]{Some(1), {}, Null[int]()},
+		Map:  map[string]Optional[int]{"a": Some(3), "b": Null[int]()},
+	}
+
+	encoder := NewEncoder()
+
+	values, errs := encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, len(values), 7)
+	Equal(t, values["Name"], []string{"Joey"})
+	Equal(t, values["Age"], []string{""})
+	Equal(t, values["IDs"], []string{"1", "2"})
+	Equal(t, values["Ints[0]"], []string{"1"})
+	Equal(t, values["Ints[2]"], []string{""})
+	Equal(t, values["Map[a]"], []string{"3"})
+	Equal(t, values["Map[b]"], []string{""})
+
+	encoder.SetNullToken("null")
+
+	values, errs = encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, values["Age"], []string{"null"})
+	Equal(t, values["Ints[2]"], []string{"null"})
+
+	decoder := NewDecoder()
+	decoder.SetNullToken("null")
+
+	var actual TestStruct
+
+	errs = decoder.Decode(&actual, values)
+	Equal(t, errs, nil)
+	Equal(t, actual, test)
+}
+
+func TestEncoderSQLNull(t *testing.T) {
+
+	type TestStruct struct {
+		String  sql.NullString
+		Invalid sql.NullInt64
+		Generic sql.Null[uint]
+		Strings []sql.NullString
+	}
+
+	test := TestStruct{
+		String:  sql.NullString{String: "Joey", Valid: true},
+		Invalid: sql.NullInt64{Int64: 3},
+		Generic: sql.Null[uint]{V: 4, Valid: true},
+		Strings: []sql.NullString{{}, {String: "a", Valid: true}},
+	}
+
+	encoder := NewEncoder()
+
+	values, errs := encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, len(values), 3)
+	Equal(t, values["String"], []string{"Joey"})
+	Equal(t, values["Generic"], []string{"4"})
+	Equal(t, values["Strings[1]"], []string{"a"})
+
+	encoder.SetNullToken("null")
+
+	values, errs = encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, len(values), 5)
+	Equal(t, values["Invalid"], []string{"null"})
+	Equal(t, values["Strings[0]"], []string{"null"})
+}
+
+type hookTrimmed struct {
+	Name string
+}
+
+func (h *hookTrimmed) BeforeFormEncode() error {
+	if len(h.Name) == 0 {
+		return errors.New("name is required")
+	}
+	h.Name = strings.TrimSpace(h.Name)
+	return nil
+}
+
+// HookTrimmed is exported so that its promoted hook can be tested on an exported embedded struct.
+type HookTrimmed struct {
+	hookTrimmed
+}
+
+func TestEncoderHooks(t *testing.T) {
+
+	type TestStruct struct {
+		Trimmed  hookTrimmed
+		Trimmeds []hookTrimmed
+		Ptr      *hookTrimmed
+	}
+
+	test := TestStruct{
+		Trimmed:  hookTrimmed{Name: " Joey "},
+		Trimmeds: []hookTrimmed{{Name: " a"}, {}},
+		Ptr:      &hookTrimmed{Name: "b "},
+	}
+
+	encoder := NewEncoder()
+
+	values, errs := encoder.Encode(test)
+	NotEqual(t, errs, nil)
+	Equal(t, errs.Error(), "Field Namespace:Trimmeds[1] ERROR:name is required")
+	Equal(t, len(values), 3)
+	Equal(t, values["Trimmed.Name"], []string{"Joey"})
+	Equal(t, values["Trimmeds[0].Name"], []string{"a"})
+	Equal(t, values["Ptr.Name"], []string{"b"})
+	Equal(t, test.Trimmed.Name, " Joey ")
+	Equal(t, test.Ptr.Name, "b ")
+
+	// the hooks of embedded structs are promoted to, and only called on, their parent
+	type Unexported struct {
+		hookTrimmed
+	}
+
+	values, errs = encoder.Encode(Unexported{hookTrimmed{Name: " c "}})
+	Equal(t, errs, nil)
+	Equal(t, values["hookTrimmed.Name"], []string{"c"})
+
+	type Exported struct {
+		HookTrimmed
+	}
+
+	values, errs = encoder.Encode(&Exported{HookTrimmed{hookTrimmed{}}})
+	NotEqual(t, errs, nil)
+
+	ee := errs.(EncodeErrors)
+	Equal(t, len(ee), 1)
+	Equal(t, ee[""].Error(), "name is required")
+}
+
+func TestEncoderContextTypeFunc(t *testing.T) {
+
+	type ctxKey struct{}
+
+	type TestStruct struct {
+		Start time.Time   `form:"start,layout=date"`
+		Times []time.Time `form:"times,layout=time"`
+		Ptrs  []*time.Time
+	}
+
+	var calls []FieldContext
+
+	encoder := NewEncoder()
+	encoder.RegisterContextTypeFunc(func(fc FieldContext, x interface{}) ([]string, error) {
+
+		calls = append(calls, fc)
+
+		layout := fc.Context.Value(ctxKey{}).(string)
+
+		for _, o := range fc.Options {
+			if o == "layout=time" {
+				layout = "15:04"
+			}
+		}
+
+		return []string{x.(time.Time).Format(layout)}, nil
+	}, time.Time{})
+
+	tm := time.Date(2016, 1, 2, 10, 30, 0, 0, time.UTC)
+
+	test := TestStruct{
+		Start: tm,
+		Times: []time.Time{tm},
+		Ptrs:  []*time.Time{&tm},
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "2006-01-02")
+
+	values, errs := encoder.EncodeContext(ctx, test)
+	Equal(t, errs, nil)
+	Equal(t, values["start"], []string{"2016-01-02"})
+	Equal(t, values["times[0]"], []string{"10:30"})
+	Equal(t, values["Ptrs[0]"], []string{"2016-01-02"})
+	Equal(t, len(calls), 3)
+	Equal(t, calls[0].Namespace, "start")
+	Equal(t, calls[0].Field.Name, "Start")
+	Equal(t, calls[1].Namespace, "times[0]")
+	Equal(t, calls[1].Options, []string{"layout=time"})
+	Equal(t, calls[2].Namespace, "Ptrs[0]")
+	Equal(t, calls[2].Field.Name, "Ptrs")
+}
+
+func TestEncoderMatchedTypeFunc(t *testing.T) {
+
+	type TestStruct struct {
+		User    testUserID
+		UserPtr *testUserID
+		Orders  []testOrderID
+		Color   testColor
+		Name    string
+		Count   int
+	}
+
+	encoder := NewEncoder()
+	encoder.RegisterMatchedTypeFunc(func(x interface{}) ([]string, error) {
+		// testUserID is passed as a pointer, only it implementing testIDer
+		return []string{x.(testIDer).ID()}, nil
+	}, MatchInterface((*testIDer)(nil)))
+	encoder.RegisterMatchedContextTypeFunc(func(fc FieldContext, x interface{}) ([]string, error) {
+		return []string{fc.Namespace + ":" + reflect.ValueOf(x).String()}, nil
+	}, MatchKind(reflect.String))
+
+	user := testUserID(2)
+
+	test := TestStruct{
+		User:    1,
+		UserPtr: &user,
+		Orders:  []testOrderID{3, 4},
+		Color:   "red",
+		Name:    "Joey",
+		Count:   5,
+	}
+
+	values, errs := encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, len(values), 7)
+	Equal(t, values["User"], []string{"u1"})
+	Equal(t, values["UserPtr"], []string{"u2"})
+	Equal(t, values["Orders[0]"], []string{"o3"})
+	Equal(t, values["Orders[1]"], []string{"o4"})
+	Equal(t, values["Color"], []string{"Color:red"})
+	Equal(t, values["Name"], []string{"Name:Joey"})
+	Equal(t, values["Count"], []string{"5"})
+
+	// addressable values are passed their own pointer
+	values, errs = encoder.Encode(&test)
+	Equal(t, errs, nil)
+	Equal(t, values["User"], []string{"u1"})
+	Equal(t, values["UserPtr"], []string{"u2"})
+
+	match := MatchInterface((*testIDer)(nil))
+	Equal(t, match(reflect.TypeOf(testUserID(0))), false)
+	Equal(t, match(reflect.TypeOf(&user)), true)
+	Equal(t, match(reflect.TypeOf(testOrderID(0))), true)
+}
+
+func TestEncoderGenericTypeFunc(t *testing.T) {
+
+	type TestStruct struct {
+		Color    testColor
+		Colors   []testColor
+		ColorMap map[testColor]int
+	}
+
+	test := TestStruct{
+		Color:    "red",
+		Colors:   []testColor{"green"},
+		ColorMap: map[testColor]int{"blue": 1},
+	}
+
+	encoder := NewEncoder()
+	RegisterEncodeFunc(encoder, func(x testColor) ([]string, error) {
+		return []string{strings.ToUpper(string(x))}, nil
+	})
+
+	values, errs := encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, len(values), 3)
+	Equal(t, values["Color"], []string{"RED"})
+	Equal(t, values["Colors[0]"], []string{"GREEN"})
+	Equal(t, values["ColorMap[BLUE]"], []string{"1"})
+
+	// returning no values for a map key is an error rather than a panic
+	RegisterEncodeFunc(encoder, func(x testColor) ([]string, error) {
+		return nil, nil
+	})
+
+	values, errs = encoder.Encode(test)
+	NotEqual(t, errs, nil)
+	Equal(t, errs.Error(), "Field Namespace:ColorMap ERROR:Custom type func returned no values for map key of type 'form.testColor'")
+	Equal(t, len(values), 2)
+}
+
+func TestEncoderNamedTypes(t *testing.T) {
+
+	type TestStruct struct {
+		Shape   testShape
+		Shapes  []testShape
+		ShapeMp map[string]testShape
+		Other   testShape
+		Nil     testShape
+	}
+
+	test := TestStruct{
+		Shape:   testCircle{Radius: 3},
+		Shapes:  []testShape{&testSquare{Side: 2}, &testCircle{Radius: 1}},
+		ShapeMp: map[string]testShape{"a": testCircle{Radius: 1}},
+		Other:   &testSquare{Side: 4},
+	}
+
+	encoder := NewEncoder()
+	encoder.RegisterType("circle", testCircle{})
+	encoder.RegisterType("square", &testSquare{})
+
+	values, errs := encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, len(values), 10)
+	Equal(t, values["Shape.type"], []string{"circle"})
+	Equal(t, values["Shape.Radius"], []string{"3"})
+	Equal(t, values["Shapes[0].type"], []string{"square"})
+	Equal(t, values["Shapes[0].Side"], []string{"2"})
+	Equal(t, values["Shapes[1].type"], []string{"circle"})
+	Equal(t, values["Shapes[1].Radius"], []string{"1"})
+	Equal(t, values["ShapeMp[a].type"], []string{"circle"})
+	Equal(t, values["ShapeMp[a].Radius"], []string{"1"})
+	Equal(t, values["Other.type"], []string{"square"})
+	Equal(t, values["Other.Side"], []string{"4"})
+
+	decoder := NewDecoder()
+	decoder.RegisterType("circle", testCircle{})
+	decoder.RegisterType("square", &testSquare{})
+
+	var actual TestStruct
+
+	errs = decoder.Decode(&actual, values)
+	Equal(t, errs, nil)
+	Equal(t, actual.Shape, test.Shape)
+	Equal(t, actual.Shapes[0], test.Shapes[0])
+	Equal(t, actual.Shapes[1], testCircle{Radius: 1})
+	Equal(t, actual.ShapeMp, test.ShapeMp)
+	Equal(t, actual.Other, test.Other)
+}
+
+func TestEncoderScalarPlans(t *testing.T) {
+
+	type Nested struct {
+		Age testAge
+	}
+
+	type TestStruct struct {
+		String  string
+		Bool    bool
+		Int8    int8 `form:",omitempty"`
+		Uint16  uint16
+		Float32 float32
+		Age     testAge
+		Nested  Nested
+	}
+
+	test := TestStruct{String: "str", Bool: true, Uint16: 16, Float32: 3.2, Age: 33, Nested: Nested{Age: 44}}
+
+	encoder := NewEncoder()
+
+	values, errs := encoder.Encode(&test)
+	Equal(t, errs, nil)
+	Equal(t, values, url.Values{
+		"String":     []string{"str"},
+		"Bool":       []string{"true"},
+		"Uint16":     []string{"16"},
+		"Float32":    []string{"3.2"},
+		"Age":        []string{"33"},
+		"Nested.Age": []string{"44"},
+	})
+
+	// values which aren't addressable use the reflective path
+	values, errs = encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, len(values), 6)
+	Equal(t, values["Nested.Age"], []string{"44"})
+
+	// custom type funcs registered after the plans are compiled take precedence
+	encoder.RegisterCustomTypeFunc(func(x interface{}) ([]string, error) {
+		return []string{"age"}, nil
+	}, testAge(0))
+
+	values, errs = encoder.Encode(&test)
+	Equal(t, errs, nil)
+	Equal(t, values["Age"], []string{"age"})
+	Equal(t, values["Nested.Age"], []string{"age"})
+	Equal(t, values["Uint16"], []string{"16"})
+}
@@ -1,6 +1,7 @@
 package form
 
 import (
+	"context"
 	"reflect"
 	"time"
 )
@@ -8,11 +9,32 @@ import (
 const (
 	blank              = ""
 	namespaceSeparator = '.'
+	aliasSeparator     = '|'
 	ignore             = "-"
 	fieldNS            = "Field Namespace:"
 	errorText          = " ERROR:"
+	errTagOption       = "Invalid tag option '%s' on field '%s'"
 )
 
 var (
 	timeType = reflect.TypeOf(time.Time{})
 )
+
+// FieldContext describes the value a context aware custom type func is
+// called for.
+type FieldContext struct {
+
+	// Context is the context passed to DecodeContext or EncodeContext,
+	// otherwise context.Background().
+	Context context.Context
+
+	// Namespace is the full key of the value eg. "Users[0].Name".
+	Namespace string
+
+	// Field is the struct field the value is set on, or is an element of;
+	// it is the zero StructField for values outside of any struct field.
+	Field reflect.StructField
+
+	// Options are the tag options of Field eg. []string{"omitempty", "max=5"}.
+	Options []string
+}
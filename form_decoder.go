@@ -2,6 +2,8 @@ package form
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"net/url"
 	"reflect"
 	"strings"
@@ -11,6 +13,21 @@ import (
 // DecodeCustomTypeFunc allows for registering/overriding types to be parsed.
 type DecodeCustomTypeFunc func([]string) (interface{}, error)
 
+// DecodeContextTypeFunc allows for registering/overriding types to be parsed
+// with the context of the value being decoded.
+type DecodeContextTypeFunc func(fc FieldContext, vals []string) (interface{}, error)
+
+// decodeFunc is a registered DecodeCustomTypeFunc or DecodeContextTypeFunc.
+type decodeFunc struct {
+	fn  DecodeCustomTypeFunc
+	ctx DecodeContextTypeFunc
+}
+
+type decodeMatcher struct {
+	match TypeMatcher
+	fn    decodeFunc
+}
+
 // DecodeErrors is a map of errors encountered during form decoding
 type DecodeErrors map[string]error
 
@@ -40,34 +57,273 @@ type recursiveData struct {
 	keys     []key
 }
 
-type dataMap []*recursiveData
+// dataMap holds the bracketed keys of the values being decoded, grouped by
+// and indexed on the alias before the brackets so each alias is found in
+// constant time; it's pooled, along with the recursiveData it holds.
+type dataMap struct {
+	data    []*recursiveData
+	index   map[string]*recursiveData
+	parents map[string]struct{} // namespaces with keys nested under them eg. "User" of "User.Name"
+}
+
+func newDataMap() interface{} {
+	return &dataMap{index: make(map[string]*recursiveData), parents: make(map[string]struct{})}
+}
+
+// add returns the recursiveData of alias, reusing a pooled one when
+// possible and indexing it.
+func (dm *dataMap) add(alias string) *recursiveData {
+
+	l := len(dm.data)
+
+	if l < cap(dm.data) {
+		dm.data = dm.data[:l+1]
+	} else {
+		dm.data = append(dm.data, nil)
+	}
+
+	rd := dm.data[l]
+
+	if rd == nil {
+		rd = new(recursiveData)
+		dm.data[l] = rd
+	} else {
+		rd.sliceLen = 0
+		rd.keys = rd.keys[0:0]
+	}
+
+	rd.alias = alias
+	dm.index[alias] = rd
+
+	return rd
+}
+
+// reset empties dm so it can be returned to the pool.
+func (dm *dataMap) reset() {
+
+	for k := range dm.index {
+		delete(dm.index, k)
+	}
+
+	for k := range dm.parents {
+		delete(dm.parents, k)
+	}
+
+	dm.data = dm.data[0:0]
+}
+
+// Mode determines how decoded values are applied to a value that
+// already contains data, eg. a pooled or previously loaded struct.
+type Mode uint8
+
+const (
+	// ModeMerge merges the decoded values into the existing value; fields
+	// without values are left untouched, existing slices are extended and
+	// existing maps have new keys added.
+	ModeMerge Mode = iota
+
+	// ModeReplace zeros any field for which no value was passed and replaces,
+	// rather than merges, existing slices and maps.
+	ModeReplace
+)
+
+// CaseFolding determines how form keys are matched to field names.
+type CaseFolding uint8
+
+const (
+	// FoldNone matches form keys to field names exactly.
+	FoldNone CaseFolding = iota
+
+	// FoldASCII matches form keys to field names ignoring the case of ASCII
+	// letters eg. "username", "UserName" and "USERNAME" all match.
+	FoldASCII
+
+	// FoldUnicode matches form keys to field names using Unicode simple
+	// case folding, the same rules as strings.EqualFold.
+	FoldUnicode
+)
+
+// AliasPolicy determines what happens when a form contains values for more
+// than one of a field's aliases eg. `form:"query|q"`
+type AliasPolicy uint8
+
+const (
+	// AliasFirst uses the first alias, in tag order, which has a value
+	// and ignores the rest.
+	AliasFirst AliasPolicy = iota
+
+	// AliasStrict reports an error in DecodeErrors, under the field's primary
+	// name, when more than one alias has a value.
+	AliasStrict
+)
+
+// SparsePolicy determines how indexed array keys, which leave gaps in the
+// resulting slice eg. a lone "Items[9999]", are handled.
+type SparsePolicy uint8
+
+const (
+	// SparseAllow honours the index of each element, sizing the slice to the
+	// largest index subject to the maximum array size.
+	SparseAllow SparsePolicy = iota
+
+	// SparseCompact places the indexed elements into a dense slice,
+	// preserving their relative order eg. "Items[3]" and "Items[9999]"
+	// become elements 0 and 1.
+	SparseCompact
+
+	// SparseReject reports an error in DecodeErrors, before allocating,
+	// when the slice would be larger than the number of distinct indexes
+	// provided multiplied by the factor.
+	SparseReject
+)
+
+// EmptyPolicy determines how an empty value eg. "Age=" is decoded; a slice,
+// array or map is only empty when its single value is, an empty value amongst
+// others eg. "Tags=&Tags=a" applying to its element.
+type EmptyPolicy uint8
+
+const (
+	// EmptyDefault sets strings to "" and leaves all other fields untouched.
+	EmptyDefault EmptyPolicy = iota
+
+	// EmptyIgnore leaves the field untouched, including strings.
+	EmptyIgnore
+
+	// EmptyZero sets the field to its zero value, pointers are set
+	// to point to a zero value.
+	EmptyZero
+
+	// EmptyNil sets pointers to nil and all other fields to their zero value.
+	EmptyNil
+)
+
+// NullPolicy determines how an empty value is decoded into database/sql Null
+// types eg. sql.NullString; absent values leave the field untouched.
+type NullPolicy uint8
 
-// Decoder is the main decode instance
+const (
+	// NullEmpty decodes an empty value as invalid eg. Valid: false.
+	NullEmpty NullPolicy = iota
+
+	// NullEmptyValid decodes an empty value as a valid zero value, only
+	// the null token is decoded as invalid.
+	NullEmptyValid
+)
+
+// Decoder is the main decode instance; Decode and its variants may be called
+// concurrently, but the Set and Register methods modify the decoder without
+// synchronisation and must only be called before first use. Configure it with
+// options passed to NewDecoder or derive a variant of one in use using With.
 type Decoder struct {
-	tagName         string
+	tagNames        []string
 	structCache     *structCacheMap
-	customTypeFuncs map[reflect.Type]DecodeCustomTypeFunc
+	typeLimit       int
+	customTypeFuncs map[reflect.Type]decodeFunc
+	typeMatchers    []decodeMatcher
+	matchedFuncs    *sync.Map // map[reflect.Type]*decodeFunc
+	namedTypes      map[string]reflect.Type
+	typeKey         string
 	maxArraySize    int
+	limits          Limits
+	sparsePolicy    SparsePolicy
+	sparseFactor    int
+	sliceDelim      string
+	sliceTrim       bool
+	emptyPolicy     EmptyPolicy
+	nullToken       string
+	nullPolicy      NullPolicy
+	mode            Mode
+	naming          NamingFunc
+	fold            CaseFolding
+	aliasPolicy     AliasPolicy
 	dataPool        *sync.Pool
 }
 
-// NewDecoder creates a new decoder instance with sane defaults
-func NewDecoder() *Decoder {
+// NewDecoder creates a new decoder instance with sane defaults, applying opts
+// eg. NewDecoder(WithTagName("json"), WithMaxArraySize(100))
+func NewDecoder(opts ...Option) *Decoder {
 
-	return &Decoder{
-		tagName:      "form",
-		structCache:  newStructCacheMap(),
+	d := &Decoder{
+		tagNames:     []string{"form"},
+		structCache:  newStructCacheMap(0),
 		maxArraySize: 10000,
-		dataPool: &sync.Pool{New: func() interface{} {
-			return make(dataMap, 0, 0)
-		}},
+		typeKey:      "type",
+		dataPool:     &sync.Pool{New: newDataMap},
+	}
+
+	d.apply(opts)
+
+	return d
+}
+
+// Clone returns a copy of the decoder, with its own struct cache, which
+// can be configured without affecting the original.
+func (d *Decoder) Clone() *Decoder {
+
+	c := *d
+	c.tagNames = append([]string(nil), d.tagNames...)
+	c.structCache = newStructCacheMap(d.typeLimit)
+	c.typeMatchers = append([]decodeMatcher(nil), d.typeMatchers...)
+
+	if d.customTypeFuncs != nil {
+		c.customTypeFuncs = make(map[reflect.Type]decodeFunc, len(d.customTypeFuncs))
+
+		for k, v := range d.customTypeFuncs {
+			c.customTypeFuncs[k] = v
+		}
+	}
+
+	if d.matchedFuncs != nil {
+		c.matchedFuncs = new(sync.Map)
+	}
+
+	if d.namedTypes != nil {
+		c.namedTypes = make(map[string]reflect.Type, len(d.namedTypes))
+
+		for k, v := range d.namedTypes {
+			c.namedTypes[k] = v
+		}
+	}
+
+	return &c
+}
+
+// With returns a copy of the decoder with opts applied, leaving the original,
+// which may be in use, untouched eg. strict := decoder.With(WithLimits(limits))
+func (d *Decoder) With(opts ...Option) *Decoder {
+
+	c := d.Clone()
+	c.apply(opts)
+
+	return c
+}
+
+func (d *Decoder) apply(opts []Option) {
+
+	for _, o := range opts {
+
+		if o.decoder != nil {
+			o.decoder(d)
+		}
 	}
 }
 
 // SetTagName sets the given tag name to be used by the decoder.
 // Default is "form"
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
 func (d *Decoder) SetTagName(tagName string) {
-	d.tagName = tagName
+	d.tagNames = []string{tagName}
+	d.structCache = newStructCacheMap(d.typeLimit)
+}
+
+// SetTagNames sets an ordered list of tag names to be used by the decoder,
+// the first tag present on a field is used eg. SetTagNames("form", "json")
+// will use the json tag of fields which have no form tag.
+// Default is "form"
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetTagNames(tagNames ...string) {
+	d.tagNames = tagNames
+	d.structCache = newStructCacheMap(d.typeLimit)
 }
 
 // SetMaxArraySize sets maximum array size that can be created.
@@ -75,29 +331,368 @@ func (d *Decoder) SetTagName(tagName string) {
 // avoid potential DOS or man-in-the-middle attacks using an unusually
 // high number.
 // DEFAULT: 10000
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
 func (d *Decoder) SetMaxArraySize(size uint) {
 	d.maxArraySize = int(size)
 }
 
+// SetLimits sets the Limits enforced on form input, in addition to
+// the maximum array size, to guard against hostile requests.
+// DEFAULT: no limits
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetLimits(limits Limits) {
+	d.limits = limits
+}
+
+// SetSparsePolicy sets how indexed array keys leaving gaps are handled;
+// factor is only used by SparseReject, where a factor of 0 is treated as 1
+// ie. no gaps allowed.
+// DEFAULT: SparseAllow
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetSparsePolicy(policy SparsePolicy, factor uint) {
+
+	if factor == 0 {
+		factor = 1
+	}
+
+	d.sparsePolicy = policy
+	d.sparseFactor = int(factor)
+}
+
+// SetSliceDelimiter sets a delimiter used to split each value of every slice
+// and array eg. "ids=1,2,3", optionally trimming surrounding space from each
+// part; a backslash escapes a delimiter or backslash within a part. Fields can
+// instead set their own using the "delim" and "trim" tag options
+// eg. `form:"ids,delim=,,trim"` or `form:"ids,delim=pipe"`.
+// DEFAULT: "", values are not split
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetSliceDelimiter(delim string, trim bool) {
+	d.sliceDelim = delim
+	d.sliceTrim = trim
+}
+
+// SetEmptyPolicy sets how empty values are decoded.
+// DEFAULT: EmptyDefault
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetEmptyPolicy(policy EmptyPolicy) {
+	d.emptyPolicy = policy
+}
+
+// SetNullToken sets a value eg. "null", which when decoded sets the field to
+// nil, or its zero value for fields which can't be nil; it can be used with
+// the same token set on the Encoder to round trip nil pointers.
+// DEFAULT: "", no null token
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetNullToken(token string) {
+	d.nullToken = token
+}
+
+// SetNullPolicy sets how empty values are decoded into database/sql Null types.
+// DEFAULT: NullEmpty
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetNullPolicy(policy NullPolicy) {
+	d.nullPolicy = policy
+}
+
+// SetMode sets the Mode used when decoding into a value that already
+// contains data.
+// DEFAULT: ModeMerge
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetMode(mode Mode) {
+	d.mode = mode
+}
+
+// SetNamingFunc sets the function used to name fields without a tag,
+// eg. SnakeCase; it is applied once per type when the struct is cached.
+// DEFAULT: nil, the Go field name is used as is
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetNamingFunc(fn NamingFunc) {
+	d.naming = fn
+	d.structCache = newStructCacheMap(d.typeLimit)
+}
+
+// SetCaseFolding sets how form keys are matched to field names, both
+// top level names and those within nested namespaces; map keys are always
+// matched exactly. Values of keys which fold to the same name are combined
+// in the sorted order of the keys eg. "UserName" before "username".
+// NOTE: when folding, namespaces within any DecodeErrors are reported folded.
+// DEFAULT: FoldNone
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetCaseFolding(fold CaseFolding) {
+	d.fold = fold
+	d.structCache = newStructCacheMap(d.typeLimit)
+}
+
+// SetAliasPolicy sets the AliasPolicy used when a form contains values
+// for more than one alias of the same field.
+// DEFAULT: AliasFirst
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetAliasPolicy(policy AliasPolicy) {
+	d.aliasPolicy = policy
+}
+
+// SetDynamicTypeLimit limits the number of unnamed struct types eg. those
+// built at runtime with reflect.StructOf, kept in the struct cache; once
+// reached, the oldest is evicted and parsed again should it be seen again.
+// Unnamed types declared in the program count against the limit too, named
+// types are always kept. Calling it clears the struct cache.
+// DEFAULT: 0, unlimited
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetDynamicTypeLimit(limit uint) {
+	d.typeLimit = int(limit)
+	d.structCache = newStructCacheMap(d.typeLimit)
+}
+
+// Warmup parses and caches the struct types of values, along with the struct
+// types of their fields, so that the first Decode of each doesn't have to
+// eg. Warmup(User{}, (*Order)(nil))
+func (d *Decoder) Warmup(values ...interface{}) {
+
+	seen := make(map[reflect.Type]bool)
+
+	parse := func(typ reflect.Type) *cachedStruct {
+		return d.structCache.parseStruct(typ, d.tagNames, d.naming, d.fold, d.isCustomType)
+	}
+
+	for _, v := range values {
+
+		if typ := reflect.TypeOf(v); typ != nil {
+			warmup(typ, seen, parse)
+		}
+	}
+}
+
+// plain returns if the decoder uses the default tag and naming rules, which
+// generated DecodeForm methods follow, so that they can be used.
+func (d *Decoder) plain(mode Mode) bool {
+	return mode == ModeMerge && d.naming == nil && d.fold == FoldNone && d.emptyPolicy == EmptyDefault &&
+		len(d.nullToken) == 0 && len(d.tagNames) == 1 && d.tagNames[0] == "form"
+}
+
+// RegisterType registers the type of value under name, so that a nil interface
+// field is decoded into a new value of it when the field's discriminator eg.
+// "Shape.type" is name; value should be a pointer eg. &Circle{} if only its
+// pointer implements the interface.
+// NOTE: this method is not thread-safe it is intended that these all be registered prior to any parsing
+func (d *Decoder) RegisterType(name string, value interface{}) {
+
+	if d.namedTypes == nil {
+		d.namedTypes = map[string]reflect.Type{}
+	}
+
+	d.namedTypes[name] = reflect.TypeOf(value)
+}
+
+// SetDiscriminatorKey sets the key, under the namespace of an interface field,
+// holding the name of the type registered with RegisterType to decode into.
+// DEFAULT: "type"
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (d *Decoder) SetDiscriminatorKey(key string) {
+	d.typeKey = key
+}
+
 // RegisterCustomTypeFunc registers a CustomTypeFunc against a number of types
 // NOTE: this method is not thread-safe it is intended that these all be registered prior to any parsing
 func (d *Decoder) RegisterCustomTypeFunc(fn DecodeCustomTypeFunc, types ...interface{}) {
 
-	if d.customTypeFuncs == nil {
-		d.customTypeFuncs = map[reflect.Type]DecodeCustomTypeFunc{}
+	for _, t := range types {
+		d.registerTypeFunc(decodeFunc{fn: fn}, reflect.TypeOf(t))
 	}
+}
 
+// RegisterDecodeFunc registers fn as the CustomTypeFunc for type T, which
+// unlike RegisterCustomTypeFunc ensures the value returned is of the right type.
+// NOTE: this function is not thread-safe it is intended that these all be registered prior to any parsing
+func RegisterDecodeFunc[T any](d *Decoder, fn func(vals []string) (T, error)) {
+
+	d.registerTypeFunc(decodeFunc{fn: func(vals []string) (interface{}, error) {
+		v, err := fn(vals)
+		return v, err
+	}}, reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// RegisterContextTypeFunc registers a DecodeContextTypeFunc against a number of types,
+// replacing any CustomTypeFunc registered for them.
+// NOTE: this method is not thread-safe it is intended that these all be registered prior to any parsing
+func (d *Decoder) RegisterContextTypeFunc(fn DecodeContextTypeFunc, types ...interface{}) {
 	for _, t := range types {
-		d.customTypeFuncs[reflect.TypeOf(t)] = fn
+		d.registerTypeFunc(decodeFunc{ctx: fn}, reflect.TypeOf(t))
+	}
+}
+
+// RegisterMatchedTypeFunc registers a CustomTypeFunc for every type match returns true
+// for eg. MatchInterface((*IDer)(nil)) or MatchKind(reflect.String); types registered
+// directly take precedence, then matchers in the order they were registered.
+// NOTE: this method is not thread-safe it is intended that these all be registered prior to any parsing
+func (d *Decoder) RegisterMatchedTypeFunc(fn DecodeCustomTypeFunc, match TypeMatcher) {
+	d.registerTypeMatcher(decodeFunc{fn: fn}, match)
+}
+
+// RegisterMatchedContextTypeFunc registers a DecodeContextTypeFunc for every type match
+// returns true for, see RegisterMatchedTypeFunc.
+// NOTE: this method is not thread-safe it is intended that these all be registered prior to any parsing
+func (d *Decoder) RegisterMatchedContextTypeFunc(fn DecodeContextTypeFunc, match TypeMatcher) {
+	d.registerTypeMatcher(decodeFunc{ctx: fn}, match)
+}
+
+func (d *Decoder) registerTypeFunc(fn decodeFunc, typ reflect.Type) {
+
+	if d.customTypeFuncs == nil {
+		d.customTypeFuncs = map[reflect.Type]decodeFunc{}
+	}
+
+	d.customTypeFuncs[typ] = fn
+
+	d.matchedFuncs = new(sync.Map)
+	d.structCache = newStructCacheMap(d.typeLimit)
+}
+
+func (d *Decoder) registerTypeMatcher(fn decodeFunc, match TypeMatcher) {
+	d.typeMatchers = append(d.typeMatchers, decodeMatcher{match: match, fn: fn})
+	d.matchedFuncs = new(sync.Map)
+	d.structCache = newStructCacheMap(d.typeLimit)
+}
+
+// hasTypeFuncs returns if any custom type funcs have been registered.
+func (d *Decoder) hasTypeFuncs() bool {
+	return d.customTypeFuncs != nil || d.typeMatchers != nil
+}
+
+// isCustomType returns if typ has a custom type func registered.
+func (d *Decoder) isCustomType(typ reflect.Type) bool {
+	_, ok := d.typeFunc(typ)
+	return ok
+}
+
+// typeFunc returns the custom type func for typ, resolving and caching
+// matchers the first time typ is seen.
+func (d *Decoder) typeFunc(typ reflect.Type) (decodeFunc, bool) {
+
+	if cf, ok := d.customTypeFuncs[typ]; ok || d.typeMatchers == nil || typ.Kind() == reflect.Ptr {
+		return cf, ok
 	}
+
+	if cf, ok := d.matchedFuncs.Load(typ); ok {
+
+		if cf := cf.(*decodeFunc); cf != nil {
+			return *cf, true
+		}
+
+		return decodeFunc{}, false
+	}
+
+	var cf *decodeFunc
+
+	for i := range d.typeMatchers {
+
+		if d.typeMatchers[i].match(typ) {
+			cf = &d.typeMatchers[i].fn
+			break
+		}
+	}
+
+	if cf == nil {
+		cf = d.matchPointer(typ)
+	}
+
+	d.matchedFuncs.Store(typ, cf)
+
+	if cf == nil {
+		return decodeFunc{}, false
+	}
+
+	return *cf, true
+}
+
+// matchPointer returns the func of the first matcher matching a pointer to typ, if any;
+// the value it returns is set as for any other type.
+func (d *Decoder) matchPointer(typ reflect.Type) *decodeFunc {
+
+	ptr := reflect.PtrTo(typ)
+
+	for i := range d.typeMatchers {
+
+		if d.typeMatchers[i].match(ptr) {
+			return &d.typeMatchers[i].fn
+		}
+	}
+
+	return nil
 }
 
 // Decode decodes the given values and sets the corresponding struct values
 func (d *Decoder) Decode(v interface{}, values url.Values) (err error) {
+	return d.decode(context.Background(), v, values, d.mode)
+}
+
+// DecodeContext decodes the given values and sets the corresponding struct values,
+// passing ctx to any DecodeContextTypeFunc.
+func (d *Decoder) DecodeContext(ctx context.Context, v interface{}, values url.Values) (err error) {
+	return d.decode(ctx, v, values, d.mode)
+}
+
+// DecodeWithMode decodes the given values and sets the corresponding struct values
+// using the given Mode instead of the one set on the decoder.
+func (d *Decoder) DecodeWithMode(v interface{}, values url.Values, mode Mode) (err error) {
+	return d.decode(context.Background(), v, values, mode)
+}
+
+// DecodeWithOptions decodes the given values and sets the corresponding struct values
+// with opts overriding the settings of the decoder, eg. WithLimits or WithMode, for this
+// call only while still sharing its struct cache; it panics when passed an option changing
+// how structs are parsed or custom types eg. WithTagName or WithCustomTypeFunc, see With.
+func (d *Decoder) DecodeWithOptions(v interface{}, values url.Values, opts ...Option) (err error) {
+
+	c := d.override(opts)
+
+	return c.decode(context.Background(), v, values, c.mode)
+}
+
+// override returns a shallow copy of the decoder, sharing its struct cache
+// and custom types, with opts applied.
+func (d *Decoder) override(opts []Option) *Decoder {
+
+	if len(opts) == 0 {
+		return d
+	}
+
+	c := *d
 
-	dec := &decoder{
+	for _, o := range opts {
+
+		if o.structs {
+			panic(fmt.Sprintf(errStructOption, "DecodeWithOptions"))
+		}
+
+		if o.decoder != nil {
+			o.decoder(&c)
+		}
+	}
+
+	return &c
+}
+
+func (d *Decoder) decode(ctx context.Context, v interface{}, values url.Values, mode Mode) (err error) {
+
+	dec := decoderPool.Get().(*decoder)
+	defer dec.release()
+
+	*dec = decoder{
 		d:      d,
+		ctx:    ctx,
 		values: values,
+		mode:   mode,
+		plain:  d.plain(mode),
+		scalar: d.emptyPolicy == EmptyDefault && len(d.nullToken) == 0,
+		buf:    dec.buf[:0],
+	}
+
+	if d.limits != (Limits{}) && !dec.checkLimits() {
+		return dec.errs
+	}
+
+	if d.fold != FoldNone {
+		dec.values = foldValues(dec.values, d.fold)
 	}
 	val := reflect.ValueOf(v)
 
@@ -108,12 +703,13 @@ func (d *Decoder) Decode(v interface{}, values url.Values) (err error) {
 	}
 
 	if kind != reflect.Ptr || val.Kind() != reflect.Struct {
-		dec.setFieldByType(val, nil, 0)
+		dec.setFieldByType(val, nil, 0, nil)
 	} else {
-		dec.traverseStruct(val, make([]byte, 0, 64))
+		dec.traverseStruct(val, dec.buf)
 	}
 
-	if len(dec.dm) > 0 {
+	if dec.dm != nil {
+		dec.dm.reset()
 		d.dataPool.Put(dec.dm)
 	}
 
@@ -2,14 +2,36 @@ package form
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"net/url"
 	"reflect"
 	"strings"
+	"sync"
 )
 
 // EncodeCustomTypeFunc allows for registering/overriding types to be parsed.
 type EncodeCustomTypeFunc func(x interface{}) ([]string, error)
 
+// EncodeContextTypeFunc allows for registering/overriding types to be parsed
+// with the context of the value being encoded.
+type EncodeContextTypeFunc func(fc FieldContext, x interface{}) ([]string, error)
+
+// encodeFunc is a registered EncodeCustomTypeFunc or EncodeContextTypeFunc.
+type encodeFunc struct {
+	fn  EncodeCustomTypeFunc
+	ctx EncodeContextTypeFunc
+
+	// addr is set when the func was matched on a pointer to the type,
+	// which it is then passed instead of the value.
+	addr bool
+}
+
+type encodeMatcher struct {
+	match TypeMatcher
+	fn    encodeFunc
+}
+
 // EncodeErrors is a map of errors encountered during form encoding
 type EncodeErrors map[string]error
 
@@ -27,47 +49,404 @@ func (e EncodeErrors) Error() string {
 	return strings.TrimSpace(buff.String())
 }
 
-// Encoder is the main encode instance
+// SliceStyle determines how the Encoder writes the elements of slices and arrays.
+type SliceStyle uint8
+
+const (
+	// SliceAuto avoids array indexes where possible; slices of primitives
+	// directly on the encoded struct are written as repeated keys while
+	// all others are indexed.
+	SliceAuto SliceStyle = iota
+
+	// SliceRepeated writes each element under the same key eg. "ids=1&ids=2"
+	SliceRepeated
+
+	// SliceIndexed writes each element with its index eg. "ids[0]=1&ids[1]=2"
+	SliceIndexed
+
+	// SliceBrackets writes each element with empty brackets eg. "ids[]=1&ids[]=2"
+	SliceBrackets
+
+	// SliceDelimited joins all elements into a single value using the
+	// delimiter eg. "ids=1,2"; any delimiter or backslash within an element
+	// is escaped with a backslash.
+	SliceDelimited
+)
+
+// Encoder is the main encode instance; Encode and its variants may be called
+// concurrently, but the Set and Register methods modify the encoder without
+// synchronisation and must only be called before first use. Configure it with
+// options passed to NewEncoder or derive a variant of one in use using With.
 type Encoder struct {
-	tagName         string
+	tagNames        []string
 	structCache     *structCacheMap
-	customTypeFuncs map[reflect.Type]EncodeCustomTypeFunc
+	typeLimit       int
+	naming          NamingFunc
+	sliceStyle      SliceStyle
+	sliceDelim      string
+	nullToken       string
+	customTypeFuncs map[reflect.Type]encodeFunc
+	typeMatchers    []encodeMatcher
+	matchedFuncs    *sync.Map // map[reflect.Type]*encodeFunc
+	typeNames       map[reflect.Type]string
+	typeKey         string
+}
+
+// NewEncoder creates a new encoder instance with sane defaults, applying opts
+// eg. NewEncoder(WithTagName("json"), WithSliceStyle(SliceBrackets))
+func NewEncoder(opts ...Option) *Encoder {
+
+	e := &Encoder{
+		tagNames:    []string{"form"},
+		structCache: newStructCacheMap(0),
+		sliceDelim:  ",",
+		typeKey:     "type",
+	}
+
+	e.apply(opts)
+
+	return e
+}
+
+// Clone returns a copy of the encoder, with its own struct cache, which
+// can be configured without affecting the original.
+func (e *Encoder) Clone() *Encoder {
+
+	c := *e
+	c.tagNames = append([]string(nil), e.tagNames...)
+	c.structCache = newStructCacheMap(e.typeLimit)
+	c.typeMatchers = append([]encodeMatcher(nil), e.typeMatchers...)
+
+	if e.customTypeFuncs != nil {
+		c.customTypeFuncs = make(map[reflect.Type]encodeFunc, len(e.customTypeFuncs))
+
+		for k, v := range e.customTypeFuncs {
+			c.customTypeFuncs[k] = v
+		}
+	}
+
+	if e.matchedFuncs != nil {
+		c.matchedFuncs = new(sync.Map)
+	}
+
+	if e.typeNames != nil {
+		c.typeNames = make(map[reflect.Type]string, len(e.typeNames))
+
+		for k, v := range e.typeNames {
+			c.typeNames[k] = v
+		}
+	}
+
+	return &c
+}
+
+// With returns a copy of the encoder with opts applied, leaving the original,
+// which may be in use, untouched eg. brackets := encoder.With(WithSliceStyle(SliceBrackets))
+func (e *Encoder) With(opts ...Option) *Encoder {
+
+	c := e.Clone()
+	c.apply(opts)
+
+	return c
 }
 
-// NewEncoder creates a new encoder instance with sane defaults
-func NewEncoder() *Encoder {
+func (e *Encoder) apply(opts []Option) {
+
+	for _, o := range opts {
 
-	return &Encoder{
-		tagName:     "form",
-		structCache: newStructCacheMap(),
+		if o.encoder != nil {
+			o.encoder(e)
+		}
 	}
 }
 
 // SetTagName sets the given tag name to be used by the decoder.
 // Default is "form"
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
 func (e *Encoder) SetTagName(tagName string) {
-	e.tagName = tagName
+	e.tagNames = []string{tagName}
+	e.structCache = newStructCacheMap(e.typeLimit)
+}
+
+// SetTagNames sets an ordered list of tag names to be used by the encoder,
+// the first tag present on a field is used eg. SetTagNames("form", "json")
+// will use the json tag of fields which have no form tag.
+// Default is "form"
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (e *Encoder) SetTagNames(tagNames ...string) {
+	e.tagNames = tagNames
+	e.structCache = newStructCacheMap(e.typeLimit)
+}
+
+// SetNamingFunc sets the function used to name fields without a tag,
+// eg. SnakeCase; it is applied once per type when the struct is cached.
+// DEFAULT: nil, the Go field name is used as is
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (e *Encoder) SetNamingFunc(fn NamingFunc) {
+	e.naming = fn
+	e.structCache = newStructCacheMap(e.typeLimit)
+}
+
+// SetSliceStyle sets the SliceStyle used for all slices and arrays, at every
+// level of nesting, which don't have their own set using the "slice" or "delim"
+// tag options eg. `form:"ids,slice=brackets"` or `form:"ids,delim=|"`.
+// Only slices of primitives, time.Time and custom types can be written
+// repeated, with brackets or delimited, all others are always indexed.
+// DEFAULT: SliceAuto
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (e *Encoder) SetSliceStyle(style SliceStyle) {
+	e.sliceStyle = style
+}
+
+// SetSliceDelimiter sets the delimiter used by SliceDelimited when a field
+// doesn't set its own using the "delim" tag option.
+// DEFAULT: ","
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (e *Encoder) SetSliceDelimiter(delim string) {
+	e.sliceDelim = delim
+}
+
+// SetNullToken sets a value eg. "null" written for nil pointers and invalid
+// database/sql Null types, which are otherwise omitted; it can be used with
+// the same token set on the Decoder to round trip them.
+// DEFAULT: "", nil pointers and invalid Null types are omitted
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (e *Encoder) SetNullToken(token string) {
+	e.nullToken = token
+}
+
+// SetDynamicTypeLimit limits the number of unnamed struct types eg. those
+// built at runtime with reflect.StructOf, kept in the struct cache; once
+// reached, the oldest is evicted and parsed again should it be seen again.
+// Unnamed types declared in the program count against the limit too, named
+// types are always kept. Calling it clears the struct cache.
+// DEFAULT: 0, unlimited
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (e *Encoder) SetDynamicTypeLimit(limit uint) {
+	e.typeLimit = int(limit)
+	e.structCache = newStructCacheMap(e.typeLimit)
+}
+
+// Warmup parses and caches the struct types of values, along with the struct
+// types of their fields, so that the first Encode of each doesn't have to
+// eg. Warmup(User{}, (*Order)(nil))
+func (e *Encoder) Warmup(values ...interface{}) {
+
+	seen := make(map[reflect.Type]bool)
+
+	parse := func(typ reflect.Type) *cachedStruct {
+		return e.structCache.parseStruct(typ, e.tagNames, e.naming, FoldNone, e.isCustomType)
+	}
+
+	for _, v := range values {
+
+		if typ := reflect.TypeOf(v); typ != nil {
+			warmup(typ, seen, parse)
+		}
+	}
+}
+
+// RegisterType registers the type of value under name, which is written to the
+// discriminator key eg. "Shape.type" of interface fields holding it, or a
+// pointer to it.
+// NOTE: this method is not thread-safe it is intended that these all be registered prior to any parsing
+func (e *Encoder) RegisterType(name string, value interface{}) {
+
+	if e.typeNames == nil {
+		e.typeNames = map[reflect.Type]string{}
+	}
+
+	e.typeNames[reflect.TypeOf(value)] = name
+}
+
+// SetDiscriminatorKey sets the key, under the namespace of an interface field,
+// the name of the type registered with RegisterType is written to.
+// DEFAULT: "type"
+// NOTE: this method is not thread-safe it must only be called prior to any parsing
+func (e *Encoder) SetDiscriminatorKey(key string) {
+	e.typeKey = key
 }
 
 // RegisterCustomTypeFunc registers a CustomTypeFunc against a number of types
 // NOTE: this method is not thread-safe it is intended that these all be registered prior to any parsing
 func (e *Encoder) RegisterCustomTypeFunc(fn EncodeCustomTypeFunc, types ...interface{}) {
 
-	if e.customTypeFuncs == nil {
-		e.customTypeFuncs = map[reflect.Type]EncodeCustomTypeFunc{}
+	for _, t := range types {
+		e.registerTypeFunc(encodeFunc{fn: fn}, reflect.TypeOf(t))
 	}
+}
+
+// RegisterEncodeFunc registers fn as the CustomTypeFunc for type T, receiving
+// the value already asserted to T.
+// NOTE: this function is not thread-safe it is intended that these all be registered prior to any parsing
+func RegisterEncodeFunc[T any](e *Encoder, fn func(x T) ([]string, error)) {
+
+	e.registerTypeFunc(encodeFunc{fn: func(x interface{}) ([]string, error) {
+		return fn(x.(T))
+	}}, reflect.TypeOf((*T)(nil)).Elem())
+}
 
+// RegisterContextTypeFunc registers an EncodeContextTypeFunc against a number of types,
+// replacing any CustomTypeFunc registered for them.
+// NOTE: this method is not thread-safe it is intended that these all be registered prior to any parsing
+func (e *Encoder) RegisterContextTypeFunc(fn EncodeContextTypeFunc, types ...interface{}) {
 	for _, t := range types {
-		e.customTypeFuncs[reflect.TypeOf(t)] = fn
+		e.registerTypeFunc(encodeFunc{ctx: fn}, reflect.TypeOf(t))
 	}
 }
 
+// RegisterMatchedTypeFunc registers a CustomTypeFunc for every type match returns true
+// for eg. MatchInterface((*IDer)(nil)) or MatchKind(reflect.String); types registered
+// directly take precedence, then matchers in the order they were registered.
+// NOTE: this method is not thread-safe it is intended that these all be registered prior to any parsing
+func (e *Encoder) RegisterMatchedTypeFunc(fn EncodeCustomTypeFunc, match TypeMatcher) {
+	e.registerTypeMatcher(encodeFunc{fn: fn}, match)
+}
+
+// RegisterMatchedContextTypeFunc registers an EncodeContextTypeFunc for every type match
+// returns true for, see RegisterMatchedTypeFunc.
+// NOTE: this method is not thread-safe it is intended that these all be registered prior to any parsing
+func (e *Encoder) RegisterMatchedContextTypeFunc(fn EncodeContextTypeFunc, match TypeMatcher) {
+	e.registerTypeMatcher(encodeFunc{ctx: fn}, match)
+}
+
+func (e *Encoder) registerTypeFunc(fn encodeFunc, typ reflect.Type) {
+
+	if e.customTypeFuncs == nil {
+		e.customTypeFuncs = map[reflect.Type]encodeFunc{}
+	}
+
+	e.customTypeFuncs[typ] = fn
+
+	e.matchedFuncs = new(sync.Map)
+	e.structCache = newStructCacheMap(e.typeLimit)
+}
+
+func (e *Encoder) registerTypeMatcher(fn encodeFunc, match TypeMatcher) {
+	e.typeMatchers = append(e.typeMatchers, encodeMatcher{match: match, fn: fn})
+	e.matchedFuncs = new(sync.Map)
+	e.structCache = newStructCacheMap(e.typeLimit)
+}
+
+// hasTypeFuncs returns if any custom type funcs have been registered.
+func (e *Encoder) hasTypeFuncs() bool {
+	return e.customTypeFuncs != nil || e.typeMatchers != nil
+}
+
+// isCustomType returns if typ has a custom type func registered.
+func (e *Encoder) isCustomType(typ reflect.Type) bool {
+	_, ok := e.typeFunc(typ)
+	return ok
+}
+
+// typeFunc returns the custom type func for typ, resolving and caching
+// matchers the first time typ is seen.
+func (e *Encoder) typeFunc(typ reflect.Type) (encodeFunc, bool) {
+
+	if cf, ok := e.customTypeFuncs[typ]; ok || e.typeMatchers == nil || typ.Kind() == reflect.Ptr {
+		return cf, ok
+	}
+
+	if cf, ok := e.matchedFuncs.Load(typ); ok {
+
+		if cf := cf.(*encodeFunc); cf != nil {
+			return *cf, true
+		}
+
+		return encodeFunc{}, false
+	}
+
+	var cf *encodeFunc
+
+	for i := range e.typeMatchers {
+
+		if e.typeMatchers[i].match(typ) {
+			cf = &e.typeMatchers[i].fn
+			break
+		}
+	}
+
+	if cf == nil {
+		cf = e.matchPointer(typ)
+	}
+
+	e.matchedFuncs.Store(typ, cf)
+
+	if cf == nil {
+		return encodeFunc{}, false
+	}
+
+	return *cf, true
+}
+
+// matchPointer returns the func of the first matcher matching a pointer to typ,
+// if any, set to be passed a pointer to the value.
+func (e *Encoder) matchPointer(typ reflect.Type) *encodeFunc {
+
+	ptr := reflect.PtrTo(typ)
+
+	for i := range e.typeMatchers {
+
+		if e.typeMatchers[i].match(ptr) {
+			cf := e.typeMatchers[i].fn
+			cf.addr = true
+			return &cf
+		}
+	}
+
+	return nil
+}
+
 // Encode encodes the given values and sets the corresponding struct values
 func (e *Encoder) Encode(v interface{}) (url.Values, error) {
+	return e.EncodeContext(context.Background(), v)
+}
+
+// EncodeWithOptions encodes the given values, with opts overriding the settings of
+// the encoder eg. WithSliceStyle, for this call only while still sharing its struct
+// cache; it panics when passed an option changing how structs are parsed or custom
+// types eg. WithTagName or WithCustomTypeFunc, see With.
+func (e *Encoder) EncodeWithOptions(v interface{}, opts ...Option) (url.Values, error) {
+	return e.override(opts).EncodeContext(context.Background(), v)
+}
+
+// override returns a shallow copy of the encoder, sharing its struct cache
+// and custom types, with opts applied.
+func (e *Encoder) override(opts []Option) *Encoder {
+
+	if len(opts) == 0 {
+		return e
+	}
+
+	c := *e
+
+	for _, o := range opts {
+
+		if o.structs {
+			panic(fmt.Sprintf(errStructOption, "EncodeWithOptions"))
+		}
+
+		if o.encoder != nil {
+			o.encoder(&c)
+		}
+	}
+
+	return &c
+}
+
+// EncodeContext encodes the given values and sets the corresponding struct values,
+// passing ctx to any EncodeContextTypeFunc.
+func (e *Encoder) EncodeContext(ctx context.Context, v interface{}) (url.Values, error) {
+
+	enc := encoderPool.Get().(*encoder)
+	defer enc.release()
 
-	enc := &encoder{
+	*enc = encoder{
 		e:      e,
+		ctx:    ctx,
 		values: make(url.Values),
+		plain:  e.naming == nil && len(e.tagNames) == 1 && e.tagNames[0] == "form",
+		buf:    enc.buf[:0],
 	}
 
 	val, kind := ExtractType(reflect.ValueOf(v))
@@ -76,7 +455,7 @@ func (e *Encoder) Encode(v interface{}) (url.Values, error) {
 		panic("interface must be a struct, pointer to a struct or interface containing one of the aforementioned")
 	}
 
-	enc.traverseStruct(val, make([]byte, 0, 64), -1)
+	enc.traverseStruct(val, enc.buf, -1)
 
 	if len(enc.errs) == 0 {
 		return enc.values, nil
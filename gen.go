@@ -0,0 +1,358 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"unsafe"
+)
+
+// FormDecoder is implemented by types with a DecodeForm method generated by
+// cmd/formgen, which the Decoder calls instead of decoding them using
+// reflection when it uses the default tag and naming rules.
+type FormDecoder interface {
+	DecodeForm(g *GenDecoder, ns string) bool
+}
+
+// FormEncoder is implemented by types with an EncodeForm method generated by
+// cmd/formgen, which the Encoder calls instead of encoding them using
+// reflection when it uses the default tag and naming rules.
+type FormEncoder interface {
+	EncodeForm(g *GenEncoder, ns string)
+}
+
+var (
+	formDecoderType = reflect.TypeOf((*FormDecoder)(nil)).Elem()
+	formEncoderType = reflect.TypeOf((*FormEncoder)(nil)).Elem()
+)
+
+// GenDecoder is passed to generated DecodeForm methods and the Decode
+// functions they call; it is not intended to be used directly.
+type GenDecoder struct {
+	d      *decoder
+	s      *cachedStruct // struct being decoded, for the fields passed to custom type funcs
+	key    []byte
+	keyBuf [64]byte
+}
+
+// GenEncoder is passed to generated EncodeForm methods and the Encode
+// functions they call; it is not intended to be used directly.
+type GenEncoder struct {
+	e      *encoder
+	s      *cachedStruct // struct being encoded, for the fields passed to custom type funcs
+	key    []byte
+	keyBuf [64]byte
+	idx    int
+}
+
+func (d *decoder) genDecoder() *GenDecoder {
+
+	if d.gen.d == nil {
+		d.gen.d = d
+		d.gen.key = d.gen.keyBuf[:0]
+	}
+
+	return &d.gen
+}
+
+func (e *encoder) genEncoder() *GenEncoder {
+
+	if e.gen.e == nil {
+		e.gen.e = e
+		e.gen.key = e.gen.keyBuf[:0]
+		e.gen.idx = -1
+	}
+
+	return &e.gen
+}
+
+// field returns the field of s named name, if any.
+func (s *cachedStruct) field(name string) *cachedField {
+
+	if s == nil {
+		return nil
+	}
+
+	for i := 0; i < len(s.fields); i++ {
+
+		if s.fields[i].name == name {
+			return &s.fields[i]
+		}
+	}
+
+	return nil
+}
+
+// appendKey appends the key of the field name under namespace ns to buf.
+func appendKey(buf []byte, ns string, name string) []byte {
+
+	buf = append(buf, ns...)
+
+	if len(ns) > 0 {
+		buf = append(buf, namespaceSeparator)
+	}
+
+	return append(buf, name...)
+}
+
+// lookup returns the values of the field name under namespace ns.
+func (g *GenDecoder) lookup(ns string, name string) (arr []string, ok bool) {
+
+	g.key = appendKey(g.key[:0], ns, name)
+	arr, ok = g.d.values[string(g.key)]
+
+	return arr, ok && len(arr) > 0
+}
+
+// custom decodes dst using reflection when a custom type func is registered for its type,
+// passing the func the same field as decoding the struct using reflection would.
+func (g *GenDecoder) custom(ns string, name string, dst interface{}) (handled bool, set bool) {
+
+	if !g.d.d.hasTypeFuncs() {
+		return
+	}
+
+	v := reflect.ValueOf(dst).Elem()
+
+	if _, handled = g.d.d.typeFunc(v.Type()); handled {
+		f := g.s.field(name)
+		prev := g.d.field
+		g.d.field = f
+		set = g.d.setFieldByType(v, appendKey(nil, ns, name), 0, f)
+		g.d.field = prev
+	}
+
+	return
+}
+
+// DecodeString decodes the field name under namespace ns into dst.
+func DecodeString[T ~string](g *GenDecoder, ns string, name string, dst *T) bool {
+
+	if handled, set := g.custom(ns, name, dst); handled {
+		return set
+	}
+
+	arr, ok := g.lookup(ns, name)
+	if !ok {
+		return false
+	}
+
+	*dst = T(arr[0])
+
+	return true
+}
+
+// DecodeBool decodes the field name under namespace ns into dst.
+func DecodeBool[T ~bool](g *GenDecoder, ns string, name string, dst *T) bool {
+
+	if handled, set := g.custom(ns, name, dst); handled {
+		return set
+	}
+
+	arr, ok := g.lookup(ns, name)
+	if !ok || len(arr[0]) == 0 {
+		return false
+	}
+
+	b, err := parseBool(arr[0])
+	if err != nil {
+		g.d.setError(g.key, fmt.Errorf(errInvalidBool, arr[0], reflect.TypeOf(*dst), string(g.key)))
+		return false
+	}
+
+	*dst = T(b)
+
+	return true
+}
+
+// DecodeInt decodes the field name under namespace ns into dst.
+func DecodeInt[T ~int | ~int8 | ~int16 | ~int32 | ~int64](g *GenDecoder, ns string, name string, dst *T) bool {
+
+	if handled, set := g.custom(ns, name, dst); handled {
+		return set
+	}
+
+	arr, ok := g.lookup(ns, name)
+	if !ok || len(arr[0]) == 0 {
+		return false
+	}
+
+	i64, err := strconv.ParseInt(arr[0], 10, int(unsafe.Sizeof(*dst))*8)
+	if err != nil {
+		g.d.setError(g.key, fmt.Errorf(errInvalidInt, arr[0], reflect.TypeOf(*dst), string(g.key)))
+		return false
+	}
+
+	*dst = T(i64)
+
+	return true
+}
+
+// DecodeUint decodes the field name under namespace ns into dst.
+func DecodeUint[T ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64](g *GenDecoder, ns string, name string, dst *T) bool {
+
+	if handled, set := g.custom(ns, name, dst); handled {
+		return set
+	}
+
+	arr, ok := g.lookup(ns, name)
+	if !ok || len(arr[0]) == 0 {
+		return false
+	}
+
+	u64, err := strconv.ParseUint(arr[0], 10, int(unsafe.Sizeof(*dst))*8)
+	if err != nil {
+		g.d.setError(g.key, fmt.Errorf(errInvalidUint, arr[0], reflect.TypeOf(*dst), string(g.key)))
+		return false
+	}
+
+	*dst = T(u64)
+
+	return true
+}
+
+// DecodeFloat decodes the field name under namespace ns into dst.
+func DecodeFloat[T ~float32 | ~float64](g *GenDecoder, ns string, name string, dst *T) bool {
+
+	if handled, set := g.custom(ns, name, dst); handled {
+		return set
+	}
+
+	arr, ok := g.lookup(ns, name)
+	if !ok || len(arr[0]) == 0 {
+		return false
+	}
+
+	f, err := strconv.ParseFloat(arr[0], int(unsafe.Sizeof(*dst))*8)
+	if err != nil {
+		g.d.setError(g.key, fmt.Errorf(errInvalidFloat, arr[0], reflect.TypeOf(*dst), string(g.key)))
+		return false
+	}
+
+	*dst = T(f)
+
+	return true
+}
+
+// DecodeStructField decodes the field with index i of the struct t points to,
+// under namespace ns, using reflection.
+func DecodeStructField(g *GenDecoder, ns string, t interface{}, i int) (set bool) {
+
+	v := reflect.ValueOf(t).Elem()
+	d := g.d
+
+	s, ok := d.d.structCache.Get(v.Type())
+	if !ok {
+		s = d.d.structCache.parseStruct(v.Type(), d.d.tagNames, d.d.naming, d.d.fold, d.d.isCustomType)
+	}
+
+	for j := 0; j < len(s.fields); j++ {
+
+		if s.fields[j].idx == i {
+			prev := d.field
+			set = d.setStructField(v, append(make([]byte, 0, 64), ns...), &s.fields[j])
+			d.field = prev
+			break
+		}
+	}
+
+	return
+}
+
+// custom encodes v using reflection when a custom type func is registered for its type,
+// passing the func the same field as encoding the struct using reflection would;
+// it is only called when type funcs are registered, as passing v allocates.
+func (g *GenEncoder) custom(ns string, name string, v interface{}) bool {
+
+	rv := reflect.ValueOf(v)
+
+	if _, ok := g.e.e.typeFunc(rv.Type()); !ok {
+		return false
+	}
+
+	f := g.s.field(name)
+	prev := g.e.field
+	g.e.field = f
+	g.e.setFieldByType(rv, appendKey(nil, ns, name), g.idx, f)
+	g.e.field = prev
+
+	return true
+}
+
+func (g *GenEncoder) setVal(ns string, name string, value string) {
+	g.key = appendKey(g.key[:0], ns, name)
+	g.e.setVal(g.key, g.idx, value)
+}
+
+// EncodeString encodes v as the field name under namespace ns.
+func EncodeString[T ~string](g *GenEncoder, ns string, name string, v T, omitEmpty bool) {
+
+	if (omitEmpty && len(v) == 0) || (g.e.e.hasTypeFuncs() && g.custom(ns, name, v)) {
+		return
+	}
+
+	g.setVal(ns, name, string(v))
+}
+
+// EncodeBool encodes v as the field name under namespace ns.
+func EncodeBool[T ~bool](g *GenEncoder, ns string, name string, v T, omitEmpty bool) {
+
+	if (omitEmpty && !bool(v)) || (g.e.e.hasTypeFuncs() && g.custom(ns, name, v)) {
+		return
+	}
+
+	g.setVal(ns, name, strconv.FormatBool(bool(v)))
+}
+
+// EncodeInt encodes v as the field name under namespace ns.
+func EncodeInt[T ~int | ~int8 | ~int16 | ~int32 | ~int64](g *GenEncoder, ns string, name string, v T, omitEmpty bool) {
+
+	if (omitEmpty && v == 0) || (g.e.e.hasTypeFuncs() && g.custom(ns, name, v)) {
+		return
+	}
+
+	g.setVal(ns, name, strconv.FormatInt(int64(v), 10))
+}
+
+// EncodeUint encodes v as the field name under namespace ns.
+func EncodeUint[T ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64](g *GenEncoder, ns string, name string, v T, omitEmpty bool) {
+
+	if (omitEmpty && v == 0) || (g.e.e.hasTypeFuncs() && g.custom(ns, name, v)) {
+		return
+	}
+
+	g.setVal(ns, name, strconv.FormatUint(uint64(v), 10))
+}
+
+// EncodeFloat encodes v as the field name under namespace ns.
+func EncodeFloat[T ~float32 | ~float64](g *GenEncoder, ns string, name string, v T, omitEmpty bool) {
+
+	if (omitEmpty && v == 0) || (g.e.e.hasTypeFuncs() && g.custom(ns, name, v)) {
+		return
+	}
+
+	g.setVal(ns, name, strconv.FormatFloat(float64(v), 'f', -1, int(unsafe.Sizeof(v))*8))
+}
+
+// EncodeStructField encodes the field with index i of the struct t points to,
+// under namespace ns, using reflection.
+func EncodeStructField(g *GenEncoder, ns string, t interface{}, i int) {
+
+	v := reflect.ValueOf(t).Elem()
+	e := g.e
+
+	s, ok := e.e.structCache.Get(v.Type())
+	if !ok {
+		s = e.e.structCache.parseStruct(v.Type(), e.e.tagNames, e.e.naming, FoldNone, e.e.isCustomType)
+	}
+
+	for j := 0; j < len(s.fields); j++ {
+
+		if s.fields[j].idx == i {
+			prev := e.field
+			e.setStructField(v, append(make([]byte, 0, 64), ns...), g.idx, &s.fields[j])
+			e.field = prev
+			return
+		}
+	}
+}
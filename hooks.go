@@ -0,0 +1,31 @@
+package form
+
+import "reflect"
+
+// AfterDecoder is implemented by structs that need to act once their fields
+// have been decoded, eg. to normalize or derive values; a returned error is
+// added to DecodeErrors under the struct's namespace.
+type AfterDecoder interface {
+	AfterFormDecode() error
+}
+
+// Validator is implemented by structs that validate themselves once their
+// fields have been decoded, after any AfterFormDecode; a returned error is
+// added to DecodeErrors under the struct's namespace.
+type Validator interface {
+	Validate() error
+}
+
+// BeforeEncoder is implemented by structs that need to act before their
+// fields are encoded, eg. to normalize values; it is called on a copy so the
+// value being encoded is left unchanged, a returned error is added to
+// EncodeErrors under the struct's namespace and the struct is not encoded.
+type BeforeEncoder interface {
+	BeforeFormEncode() error
+}
+
+var (
+	afterDecoderType  = reflect.TypeOf((*AfterDecoder)(nil)).Elem()
+	validatorType     = reflect.TypeOf((*Validator)(nil)).Elem()
+	beforeEncoderType = reflect.TypeOf((*BeforeEncoder)(nil)).Elem()
+)
@@ -0,0 +1,152 @@
+package form
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Limits are guards against hostile or unusually large form input, they
+// are checked before any values are decoded or any memory is allocated for
+// them. A limit of zero, the default, means no limit.
+type Limits struct {
+
+	// MaxKeys is the maximum number of keys in the form, if exceeded
+	// nothing is decoded.
+	MaxKeys int
+
+	// MaxValues is the maximum total number of values across all keys,
+	// if exceeded nothing is decoded; it also limits the number of elements
+	// of a slice decoded from repeated or delimited values.
+	MaxValues int
+
+	// MaxValueLength is the maximum length of any single value, keys
+	// with a longer value are skipped.
+	MaxValueLength int
+
+	// MaxDepth is the maximum nesting depth of any key eg. "a.b[0].c" has
+	// a depth of 4, deeper keys are skipped.
+	MaxDepth int
+
+	// MaxKeySegments is the maximum number of bracket segments in any key
+	// eg. "a[0][1]" has 2, keys with more are skipped.
+	MaxKeySegments int
+
+	// MaxMapEntries is the maximum total number of map entries created
+	// across all maps being decoded.
+	MaxMapEntries int
+}
+
+// LimitError is the error reported in DecodeErrors when form input exceeds
+// one of the Limits; limits covering the whole form are reported under a
+// blank namespace.
+type LimitError struct {
+	Limit string // name of the exceeded limit eg. "MaxKeys"
+	Max   int
+	Value int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("Limit %s of '%d' exceeded with '%d'", e.Limit, e.Max, e.Value)
+}
+
+// checkLimits checks the form values against the decoder's Limits, removing
+// any individual keys that exceed them. It returns false if the form as a
+// whole exceeds them and nothing should be decoded.
+func (d *decoder) checkLimits() bool {
+
+	limits := d.d.limits
+
+	if limits.MaxKeys > 0 && len(d.values) > limits.MaxKeys {
+		d.setError(nil, &LimitError{Limit: "MaxKeys", Max: limits.MaxKeys, Value: len(d.values)})
+		return false
+	}
+
+	var total int
+	var skip []string
+
+	for k, v := range d.values {
+
+		total += len(v)
+
+		if err := checkKeyLimits(k, v, limits); err != nil {
+			d.setError([]byte(k), err)
+			skip = append(skip, k)
+		}
+	}
+
+	if limits.MaxValues > 0 && total > limits.MaxValues {
+		d.setError(nil, &LimitError{Limit: "MaxValues", Max: limits.MaxValues, Value: total})
+		return false
+	}
+
+	if len(skip) == 0 {
+		return true
+	}
+
+	// never modify the caller's values
+	values := make(url.Values, len(d.values))
+
+	for k, v := range d.values {
+		values[k] = v
+	}
+
+	for _, k := range skip {
+		delete(values, k)
+	}
+
+	d.values = values
+
+	return true
+}
+
+func checkKeyLimits(k string, v []string, limits Limits) error {
+
+	if limits.MaxValueLength > 0 {
+
+		for _, s := range v {
+
+			if len(s) > limits.MaxValueLength {
+				return &LimitError{Limit: "MaxValueLength", Max: limits.MaxValueLength, Value: len(s)}
+			}
+		}
+	}
+
+	if limits.MaxDepth == 0 && limits.MaxKeySegments == 0 {
+		return nil
+	}
+
+	depth := 1
+	var segments int
+	var insideBracket bool
+
+	for i := 0; i < len(k); i++ {
+
+		switch k[i] {
+		case '[':
+			insideBracket = true
+			segments++
+			depth++
+		case ']':
+			insideBracket = false
+		case namespaceSeparator:
+			if !insideBracket {
+				depth++
+			}
+		}
+	}
+
+	// a key starting with a bracket eg. "[0].Name" has no leading name segment
+	if len(k) > 0 && k[0] == '[' {
+		depth--
+	}
+
+	if limits.MaxKeySegments > 0 && segments > limits.MaxKeySegments {
+		return &LimitError{Limit: "MaxKeySegments", Max: limits.MaxKeySegments, Value: segments}
+	}
+
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+		return &LimitError{Limit: "MaxDepth", Max: limits.MaxDepth, Value: depth}
+	}
+
+	return nil
+}
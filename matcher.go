@@ -0,0 +1,46 @@
+package form
+
+import "reflect"
+
+// TypeMatcher reports whether a custom type func registered with it applies
+// to typ; it is called at most once per type, with pointer types never
+// matched but instead their element type once allocated. A type no matcher
+// applies to is tried again as a pointer to it, so that types implementing
+// an interface on their pointer are matched, an encode func then receiving
+// a pointer to the value.
+type TypeMatcher func(typ reflect.Type) bool
+
+// MatchInterface returns a TypeMatcher matching types that implement the
+// interface iface points to eg. MatchInterface((*fmt.Stringer)(nil)),
+// either directly or on their pointer, see TypeMatcher.
+func MatchInterface(iface interface{}) TypeMatcher {
+
+	typ := reflect.TypeOf(iface)
+
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Interface {
+		panic("MatchInterface requires a nil pointer to an interface eg. (*fmt.Stringer)(nil)")
+	}
+
+	typ = typ.Elem()
+
+	return func(t reflect.Type) bool {
+		return t.Implements(typ)
+	}
+}
+
+// MatchKind returns a TypeMatcher matching types of any of the given kinds,
+// eg. all defined string types with MatchKind(reflect.String).
+func MatchKind(kinds ...reflect.Kind) TypeMatcher {
+
+	return func(t reflect.Type) bool {
+
+		for _, k := range kinds {
+
+			if t.Kind() == k {
+				return true
+			}
+		}
+
+		return false
+	}
+}
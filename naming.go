@@ -0,0 +1,110 @@
+package form
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NamingFunc converts the Go name of a field without a tag into the
+// name used for it in url.Values.
+type NamingFunc func(name string) string
+
+// SnakeCase is a NamingFunc converting field names to snake_case
+// eg. "UserID" becomes "user_id"
+func SnakeCase(name string) string {
+	return joinWords(splitWords(name), '_')
+}
+
+// KebabCase is a NamingFunc converting field names to kebab-case
+// eg. "UserID" becomes "user-id"
+func KebabCase(name string) string {
+	return joinWords(splitWords(name), '-')
+}
+
+// CamelCase is a NamingFunc converting field names to camelCase
+// eg. "UserID" becomes "userId"
+func CamelCase(name string) string {
+
+	words := splitWords(name)
+	buff := make([]byte, 0, len(name))
+
+	for i, w := range words {
+
+		w = strings.ToLower(w)
+
+		if i > 0 {
+			r, size := utf8.DecodeRuneInString(w)
+			buff = utf8.AppendRune(buff, unicode.ToUpper(r))
+			w = w[size:]
+		}
+
+		buff = append(buff, w...)
+	}
+
+	return string(buff)
+}
+
+// LowerCase is a NamingFunc converting field names to all lowercase
+// eg. "UserID" becomes "userid"
+func LowerCase(name string) string {
+	return strings.ToLower(name)
+}
+
+func joinWords(words []string, sep byte) string {
+
+	buff := make([]byte, 0, len(words)*8)
+
+	for i, w := range words {
+
+		if i > 0 {
+			buff = append(buff, sep)
+		}
+
+		buff = append(buff, strings.ToLower(w)...)
+	}
+
+	return string(buff)
+}
+
+// splitWords splits a Go identifier into its words, keeping acronyms and
+// trailing digits together eg. "HTTPServer2Addr" becomes "HTTP", "Server2", "Addr"
+func splitWords(name string) []string {
+
+	words := make([]string, 0, 4)
+	runes := []rune(name)
+	start := 0
+
+	for i := 0; i < len(runes); i++ {
+
+		r := runes[i]
+
+		if r == '_' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+
+			start = i + 1
+			continue
+		}
+
+		if i == start || !unicode.IsUpper(r) {
+			continue
+		}
+
+		prev := runes[i-1]
+
+		// lower or digit followed by upper eg. "userName" or "v2Config"
+		// or the last upper of an acronym followed by a lower eg. "HTTPServer"
+		if !unicode.IsUpper(prev) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
@@ -0,0 +1,34 @@
+package form
+
+import (
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestNamingFuncs(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		snake string
+		kebab string
+		camel string
+		lower string
+	}{
+		{name: "Name", snake: "name", kebab: "name", camel: "name", lower: "name"},
+		{name: "UserName", snake: "user_name", kebab: "user-name", camel: "userName", lower: "username"},
+		{name: "UserID", snake: "user_id", kebab: "user-id", camel: "userId", lower: "userid"},
+		{name: "HTTPServer", snake: "http_server", kebab: "http-server", camel: "httpServer", lower: "httpserver"},
+		{name: "Address2", snake: "address2", kebab: "address2", camel: "address2", lower: "address2"},
+		{name: "V2Config", snake: "v2_config", kebab: "v2-config", camel: "v2Config", lower: "v2config"},
+		{name: "Already_Snake", snake: "already_snake", kebab: "already-snake", camel: "alreadySnake", lower: "already_snake"},
+		{name: "ID", snake: "id", kebab: "id", camel: "id", lower: "id"},
+	}
+
+	for _, tt := range tests {
+		Equal(t, SnakeCase(tt.name), tt.snake)
+		Equal(t, KebabCase(tt.name), tt.kebab)
+		Equal(t, CamelCase(tt.name), tt.camel)
+		Equal(t, LowerCase(tt.name), tt.lower)
+	}
+}
@@ -0,0 +1,38 @@
+package form
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Optional holds a value which may be absent, explicitly null or set, allowing
+// eg. PATCH requests to tell a field that was not sent from one being cleared.
+//
+// When decoding Present is set when the field's key is found and Null when its
+// value is the Decoder's null token, or empty when no null token is set;
+// otherwise Value is decoded as T normally would be.
+//
+// When encoding nothing is written unless Present, and a Null Optional
+// writes the Encoder's null token.
+type Optional[T any] struct {
+	Value   T
+	Present bool
+	Null    bool
+}
+
+// Some returns an Optional that is present and set to value.
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{Value: value, Present: true}
+}
+
+// Null returns an Optional that is present and explicitly null.
+func Null[T any]() Optional[T] {
+	return Optional[T]{Present: true, Null: true}
+}
+
+var optionalPkgPath = reflect.TypeOf(Optional[int]{}).PkgPath()
+
+// isOptional returns whether typ is an instance of Optional.
+func isOptional(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && typ.PkgPath() == optionalPkgPath && strings.HasPrefix(typ.Name(), "Optional[")
+}
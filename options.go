@@ -0,0 +1,207 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+)
+
+const (
+	errCustomTypeOption = "%s requires a DecodeCustomTypeFunc, DecodeContextTypeFunc, EncodeCustomTypeFunc or EncodeContextTypeFunc"
+	errStructOption     = "%s can't override options which change how structs are parsed or custom types eg. WithTagName, use With instead"
+)
+
+// Option configures a Decoder or Encoder, see NewDecoder, NewEncoder and their
+// With methods; an option which only applies to one of them eg. WithMaxArraySize,
+// is ignored by the other.
+type Option struct {
+	decoder func(d *Decoder)
+	encoder func(e *Encoder)
+
+	// structs is set by options changing how structs are parsed, or the
+	// custom types and type names which are shared, so can't be per call.
+	structs bool
+}
+
+// WithTagName sets the tag name, see Decoder.SetTagName and Encoder.SetTagName.
+func WithTagName(tagName string) Option {
+	return Option{
+		decoder: func(d *Decoder) { d.SetTagName(tagName) },
+		encoder: func(e *Encoder) { e.SetTagName(tagName) },
+		structs: true,
+	}
+}
+
+// WithTagNames sets an ordered list of tag names, see Decoder.SetTagNames
+// and Encoder.SetTagNames.
+func WithTagNames(tagNames ...string) Option {
+	return Option{
+		decoder: func(d *Decoder) { d.SetTagNames(tagNames...) },
+		encoder: func(e *Encoder) { e.SetTagNames(tagNames...) },
+		structs: true,
+	}
+}
+
+// WithNamingFunc sets the function used to name fields without a tag,
+// see Decoder.SetNamingFunc and Encoder.SetNamingFunc.
+func WithNamingFunc(fn NamingFunc) Option {
+	return Option{
+		decoder: func(d *Decoder) { d.SetNamingFunc(fn) },
+		encoder: func(e *Encoder) { e.SetNamingFunc(fn) },
+		structs: true,
+	}
+}
+
+// WithSliceDelimiter sets the delimiter of delimited slices, see Decoder.SetSliceDelimiter
+// and Encoder.SetSliceDelimiter; trim only applies to a Decoder.
+func WithSliceDelimiter(delim string, trim bool) Option {
+	return Option{
+		decoder: func(d *Decoder) { d.SetSliceDelimiter(delim, trim) },
+		encoder: func(e *Encoder) { e.SetSliceDelimiter(delim) },
+	}
+}
+
+// WithNullToken sets the null token, see Decoder.SetNullToken and Encoder.SetNullToken.
+func WithNullToken(token string) Option {
+	return Option{
+		decoder: func(d *Decoder) { d.SetNullToken(token) },
+		encoder: func(e *Encoder) { e.SetNullToken(token) },
+	}
+}
+
+// WithDynamicTypeLimit limits the number of unnamed struct types cached,
+// see Decoder.SetDynamicTypeLimit and Encoder.SetDynamicTypeLimit.
+func WithDynamicTypeLimit(limit uint) Option {
+	return Option{
+		decoder: func(d *Decoder) { d.SetDynamicTypeLimit(limit) },
+		encoder: func(e *Encoder) { e.SetDynamicTypeLimit(limit) },
+		structs: true,
+	}
+}
+
+// WithRegisteredType registers the type of value under name, see
+// Decoder.RegisterType and Encoder.RegisterType.
+func WithRegisteredType(name string, value interface{}) Option {
+	return Option{
+		decoder: func(d *Decoder) { d.RegisterType(name, value) },
+		encoder: func(e *Encoder) { e.RegisterType(name, value) },
+		structs: true,
+	}
+}
+
+// WithDiscriminatorKey sets the discriminator key, see Decoder.SetDiscriminatorKey
+// and Encoder.SetDiscriminatorKey.
+func WithDiscriminatorKey(key string) Option {
+	return Option{
+		decoder: func(d *Decoder) { d.SetDiscriminatorKey(key) },
+		encoder: func(e *Encoder) { e.SetDiscriminatorKey(key) },
+	}
+}
+
+// WithMaxArraySize sets the maximum array size of a Decoder, see Decoder.SetMaxArraySize.
+func WithMaxArraySize(size uint) Option {
+	return Option{decoder: func(d *Decoder) { d.SetMaxArraySize(size) }}
+}
+
+// WithLimits sets the limits of a Decoder, see Decoder.SetLimits.
+func WithLimits(limits Limits) Option {
+	return Option{decoder: func(d *Decoder) { d.SetLimits(limits) }}
+}
+
+// WithSparsePolicy sets the SparsePolicy of a Decoder, see Decoder.SetSparsePolicy.
+func WithSparsePolicy(policy SparsePolicy, factor uint) Option {
+	return Option{decoder: func(d *Decoder) { d.SetSparsePolicy(policy, factor) }}
+}
+
+// WithEmptyPolicy sets the EmptyPolicy of a Decoder, see Decoder.SetEmptyPolicy.
+func WithEmptyPolicy(policy EmptyPolicy) Option {
+	return Option{decoder: func(d *Decoder) { d.SetEmptyPolicy(policy) }}
+}
+
+// WithNullPolicy sets the NullPolicy of a Decoder, see Decoder.SetNullPolicy.
+func WithNullPolicy(policy NullPolicy) Option {
+	return Option{decoder: func(d *Decoder) { d.SetNullPolicy(policy) }}
+}
+
+// WithMode sets the default Mode of a Decoder, see Decoder.SetMode.
+func WithMode(mode Mode) Option {
+	return Option{decoder: func(d *Decoder) { d.SetMode(mode) }}
+}
+
+// WithCaseFolding sets the CaseFolding of a Decoder, see Decoder.SetCaseFolding.
+func WithCaseFolding(fold CaseFolding) Option {
+	return Option{decoder: func(d *Decoder) { d.SetCaseFolding(fold) }, structs: true}
+}
+
+// WithAliasPolicy sets the AliasPolicy of a Decoder, see Decoder.SetAliasPolicy.
+func WithAliasPolicy(policy AliasPolicy) Option {
+	return Option{decoder: func(d *Decoder) { d.SetAliasPolicy(policy) }}
+}
+
+// WithSliceStyle sets the SliceStyle of an Encoder, see Encoder.SetSliceStyle.
+func WithSliceStyle(style SliceStyle) Option {
+	return Option{encoder: func(e *Encoder) { e.SetSliceStyle(style) }}
+}
+
+// WithCustomTypeFunc registers fn against a number of types; fn is a DecodeCustomTypeFunc
+// or DecodeContextTypeFunc, applying to a Decoder, or an EncodeCustomTypeFunc or
+// EncodeContextTypeFunc, applying to an Encoder.
+func WithCustomTypeFunc(fn interface{}, types ...interface{}) Option {
+	return customTypeOption("WithCustomTypeFunc", fn,
+		func(d *Decoder, cf decodeFunc) {
+			for _, t := range types {
+				d.registerTypeFunc(cf, reflect.TypeOf(t))
+			}
+		},
+		func(e *Encoder, cf encodeFunc) {
+			for _, t := range types {
+				e.registerTypeFunc(cf, reflect.TypeOf(t))
+			}
+		})
+}
+
+// WithMatchedTypeFunc registers fn, as accepted by WithCustomTypeFunc, for every type
+// match returns true for, see Decoder.RegisterMatchedTypeFunc.
+func WithMatchedTypeFunc(fn interface{}, match TypeMatcher) Option {
+	return customTypeOption("WithMatchedTypeFunc", fn,
+		func(d *Decoder, cf decodeFunc) { d.registerTypeMatcher(cf, match) },
+		func(e *Encoder, cf encodeFunc) { e.registerTypeMatcher(cf, match) })
+}
+
+// WithDecodeFunc registers fn as the custom type func of a Decoder for type T,
+// see RegisterDecodeFunc.
+func WithDecodeFunc[T any](fn func(vals []string) (T, error)) Option {
+	return Option{decoder: func(d *Decoder) { RegisterDecodeFunc(d, fn) }, structs: true}
+}
+
+// WithEncodeFunc registers fn as the custom type func of an Encoder for type T,
+// see RegisterEncodeFunc.
+func WithEncodeFunc[T any](fn func(x T) ([]string, error)) Option {
+	return Option{encoder: func(e *Encoder) { RegisterEncodeFunc(e, fn) }, structs: true}
+}
+
+// customTypeOption returns an Option registering the custom type func fn with
+// dec or enc, depending on its type; it panics, naming the option, when fn isn't
+// one of the custom type func types.
+func customTypeOption(option string, fn interface{}, dec func(*Decoder, decodeFunc), enc func(*Encoder, encodeFunc)) Option {
+
+	switch fn := fn.(type) {
+	case DecodeCustomTypeFunc:
+		return Option{decoder: func(d *Decoder) { dec(d, decodeFunc{fn: fn}) }, structs: true}
+	case func([]string) (interface{}, error):
+		return Option{decoder: func(d *Decoder) { dec(d, decodeFunc{fn: fn}) }, structs: true}
+	case DecodeContextTypeFunc:
+		return Option{decoder: func(d *Decoder) { dec(d, decodeFunc{ctx: fn}) }, structs: true}
+	case func(FieldContext, []string) (interface{}, error):
+		return Option{decoder: func(d *Decoder) { dec(d, decodeFunc{ctx: fn}) }, structs: true}
+	case EncodeCustomTypeFunc:
+		return Option{encoder: func(e *Encoder) { enc(e, encodeFunc{fn: fn}) }, structs: true}
+	case func(interface{}) ([]string, error):
+		return Option{encoder: func(e *Encoder) { enc(e, encodeFunc{fn: fn}) }, structs: true}
+	case EncodeContextTypeFunc:
+		return Option{encoder: func(e *Encoder) { enc(e, encodeFunc{ctx: fn}) }, structs: true}
+	case func(FieldContext, interface{}) ([]string, error):
+		return Option{encoder: func(e *Encoder) { enc(e, encodeFunc{ctx: fn}) }, structs: true}
+	}
+
+	panic(fmt.Sprintf(errCustomTypeOption, option))
+}
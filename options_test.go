@@ -0,0 +1,210 @@
+package form
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestDecoderOptions(t *testing.T) {
+
+	type TestStruct struct {
+		Name  string `json:"name"`
+		Age   testAge
+		Count int
+		IDs   []int
+	}
+
+	decoder := NewDecoder(
+		WithTagName("json"),
+		WithMaxArraySize(2),
+		WithSliceStyle(SliceBrackets), // encoder only, ignored
+		WithCustomTypeFunc(func(vals []string) (interface{}, error) {
+			return testAge(len(vals[0])), nil
+		}, testAge(0)),
+		WithMatchedTypeFunc(DecodeContextTypeFunc(func(fc FieldContext, vals []string) (interface{}, error) {
+			return len(fc.Namespace) * 100, nil
+		}), MatchKind(reflect.Int)),
+	)
+
+	values := url.Values{
+		"name":   []string{"joey"},
+		"Age":    []string{"abc"},
+		"Count":  []string{"1"},
+		"IDs[5]": []string{"1"},
+	}
+
+	var test TestStruct
+
+	errs := decoder.Decode(&test, values)
+	NotEqual(t, errs, nil)
+	Equal(t, len(errs.(DecodeErrors)), 1)
+	Equal(t, errs.(DecodeErrors)["IDs"].Error(), "Array size of '6' is larger than the maximum currently set on the decoder of '2'. To increase this limit please see, SetMaxArraySize(size uint)")
+	Equal(t, test.Name, "joey")
+	Equal(t, test.Age, testAge(3))
+	Equal(t, test.Count, 500)
+
+	decoder = NewDecoder(WithDecodeFunc(func(vals []string) (int, error) {
+		return 0, errors.New("bad int")
+	}))
+
+	test = TestStruct{}
+
+	errs = decoder.Decode(&test, url.Values{"Count": []string{"1"}})
+	NotEqual(t, errs, nil)
+	Equal(t, errs.(DecodeErrors)["Count"].Error(), "bad int")
+}
+
+func TestDecoderWith(t *testing.T) {
+
+	type TestStruct struct {
+		Value int `form:"value" json:"v"`
+	}
+
+	values := url.Values{"value": []string{"1"}, "v": []string{"2"}}
+
+	decoder := NewDecoder()
+
+	var test TestStruct
+
+	errs := decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Value, 1)
+
+	// the variant has its own struct cache and custom type funcs
+	variant := decoder.With(WithTagName("json"), WithDecodeFunc(func(vals []string) (int, error) {
+		return strconv.Atoi(vals[0] + vals[0])
+	}))
+
+	errs = variant.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Value, 22)
+
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Value, 1)
+
+	clone := variant.Clone()
+	clone.RegisterType("circle", testCircle{})
+
+	Equal(t, len(variant.namedTypes), 0)
+	Equal(t, len(clone.customTypeFuncs), 1)
+	NotEqual(t, clone.structCache, variant.structCache)
+}
+
+func TestEncoderOptions(t *testing.T) {
+
+	type TestStruct struct {
+		Name string `json:"name"`
+		Age  testAge
+		IDs  []int
+	}
+
+	encoder := NewEncoder(
+		WithTagName("json"),
+		WithSliceStyle(SliceDelimited),
+		WithSliceDelimiter("|", true),
+		WithMaxArraySize(1), // decoder only, ignored
+		WithCustomTypeFunc(func(x interface{}) ([]string, error) {
+			return []string{"age" + strconv.Itoa(int(x.(testAge)))}, nil
+		}, testAge(0)),
+	)
+
+	values, errs := encoder.Encode(TestStruct{Name: "joey", Age: 3, IDs: []int{1, 2}})
+	Equal(t, errs, nil)
+	Equal(t, values, url.Values{
+		"name": []string{"joey"},
+		"Age":  []string{"age3"},
+		"IDs":  []string{"1|2"},
+	})
+
+	variant := encoder.With(WithSliceStyle(SliceBrackets), WithEncodeFunc(func(a testAge) ([]string, error) {
+		return []string{"old"}, nil
+	}))
+
+	values, errs = variant.Encode(TestStruct{Age: 3, IDs: []int{1, 2}})
+	Equal(t, errs, nil)
+	Equal(t, values["IDs[]"], []string{"1", "2"})
+	Equal(t, values["Age"], []string{"old"})
+
+	values, errs = encoder.Encode(TestStruct{Age: 3, IDs: []int{1, 2}})
+	Equal(t, errs, nil)
+	Equal(t, values["IDs"], []string{"1|2"})
+	Equal(t, values["Age"], []string{"age3"})
+}
+
+func TestCustomTypeOptionPanics(t *testing.T) {
+
+	PanicMatches(t, func() { WithCustomTypeFunc(func(s string) {}, testAge(0)) }, "WithCustomTypeFunc requires a DecodeCustomTypeFunc, DecodeContextTypeFunc, EncodeCustomTypeFunc or EncodeContextTypeFunc")
+	PanicMatches(t, func() { WithMatchedTypeFunc(nil, MatchKind(reflect.Int)) }, "WithMatchedTypeFunc requires a DecodeCustomTypeFunc, DecodeContextTypeFunc, EncodeCustomTypeFunc or EncodeContextTypeFunc")
+}
+
+func TestDecoderWithOptions(t *testing.T) {
+
+	type TestStruct struct {
+		Name string
+		IDs  []int
+	}
+
+	decoder := NewDecoder()
+
+	values := url.Values{"Name": []string{""}, "IDs[4]": []string{"1"}}
+
+	test := TestStruct{Name: "joey", IDs: []int{9}}
+
+	errs := decoder.DecodeWithOptions(&test, values, WithMaxArraySize(2), WithEmptyPolicy(EmptyIgnore))
+	NotEqual(t, errs, nil)
+	Equal(t, len(errs.(DecodeErrors)), 1)
+	NotEqual(t, errs.(DecodeErrors)["IDs"], nil)
+	Equal(t, test.Name, "joey")
+	Equal(t, test.IDs, []int{9})
+
+	// the decoder's own settings are untouched, and its struct cache shared
+	typ := reflect.TypeOf(test)
+	cs, ok := decoder.structCache.Get(typ)
+	Equal(t, ok, true)
+
+	test = TestStruct{Name: "joey"}
+
+	errs = decoder.Decode(&test, values)
+	Equal(t, errs, nil)
+	Equal(t, test.Name, "")
+	Equal(t, test.IDs, []int{0, 0, 0, 0, 1})
+
+	cached, _ := decoder.structCache.Get(typ)
+	Equal(t, cached == cs, true)
+
+	errs = decoder.DecodeWithOptions(&test, values)
+	Equal(t, errs, nil)
+
+	PanicMatches(t, func() { decoder.DecodeWithOptions(&test, values, WithTagName("json")) }, "DecodeWithOptions can't override options which change how structs are parsed or custom types eg. WithTagName, use With instead")
+	PanicMatches(t, func() {
+		decoder.DecodeWithOptions(&test, values, WithMatchedTypeFunc(DecodeCustomTypeFunc(nil), MatchKind(reflect.Int)))
+	}, "DecodeWithOptions can't override options which change how structs are parsed or custom types eg. WithTagName, use With instead")
+}
+
+func TestEncoderWithOptions(t *testing.T) {
+
+	type TestStruct struct {
+		Name *string
+		IDs  []int
+	}
+
+	encoder := NewEncoder()
+
+	test := TestStruct{IDs: []int{1, 2}}
+
+	values, errs := encoder.EncodeWithOptions(test, WithSliceStyle(SliceDelimited), WithSliceDelimiter("|", false), WithNullToken("null"))
+	Equal(t, errs, nil)
+	Equal(t, values, url.Values{"Name": []string{"null"}, "IDs": []string{"1|2"}})
+
+	values, errs = encoder.Encode(test)
+	Equal(t, errs, nil)
+	Equal(t, values, url.Values{"IDs": []string{"1", "2"}})
+
+	PanicMatches(t, func() { encoder.EncodeWithOptions(test, WithRegisteredType("circle", testCircle{})) }, "EncodeWithOptions can't override options which change how structs are parsed or custom types eg. WithTagName, use With instead")
+}
@@ -0,0 +1,135 @@
+package form
+
+import (
+	"reflect"
+	"strconv"
+	"unsafe"
+)
+
+// scalarPlan decodes and encodes a string, bool or number field directly
+// through a pointer to it, skipping the type switch of setFieldByType.
+type scalarPlan struct {
+	decode func(p unsafe.Pointer, s string) bool
+	encode func(p unsafe.Pointer) string
+	err    string // format of the error reported when decode fails
+}
+
+const (
+	errInvalidUint  = "Invalid Unsigned Integer Value '%s' Type '%v' Namespace '%s'"
+	errInvalidInt   = "Invalid Integer Value '%s' Type '%v' Namespace '%s'"
+	errInvalidFloat = "Invalid Float Value '%s' Type '%v' Namespace '%s'"
+	errInvalidBool  = "Invalid Boolean Value '%s' Type '%v' Namespace '%s'"
+)
+
+// scalarPlans holds the plan of each kind that can be decoded and encoded
+// directly, indexed by kind; the plan is chosen by kind so that named types
+// eg. type Age uint8, share the plan of their underlying type.
+var scalarPlans = [...]*scalarPlan{
+	reflect.String:  {decode: decodeString, encode: encodeString},
+	reflect.Bool:    {decode: decodeBool, encode: encodeBool, err: errInvalidBool},
+	reflect.Int:     {decode: decodeInt[int], encode: encodeInt[int], err: errInvalidInt},
+	reflect.Int8:    {decode: decodeInt[int8], encode: encodeInt[int8], err: errInvalidInt},
+	reflect.Int16:   {decode: decodeInt[int16], encode: encodeInt[int16], err: errInvalidInt},
+	reflect.Int32:   {decode: decodeInt[int32], encode: encodeInt[int32], err: errInvalidInt},
+	reflect.Int64:   {decode: decodeInt[int64], encode: encodeInt[int64], err: errInvalidInt},
+	reflect.Uint:    {decode: decodeUint[uint], encode: encodeUint[uint], err: errInvalidUint},
+	reflect.Uint8:   {decode: decodeUint[uint8], encode: encodeUint[uint8], err: errInvalidUint},
+	reflect.Uint16:  {decode: decodeUint[uint16], encode: encodeUint[uint16], err: errInvalidUint},
+	reflect.Uint32:  {decode: decodeUint[uint32], encode: encodeUint[uint32], err: errInvalidUint},
+	reflect.Uint64:  {decode: decodeUint[uint64], encode: encodeUint[uint64], err: errInvalidUint},
+	reflect.Float32: {decode: decodeFloat[float32], encode: encodeFloat[float32], err: errInvalidFloat},
+	reflect.Float64: {decode: decodeFloat[float64], encode: encodeFloat[float64], err: errInvalidFloat},
+}
+
+// planScalar returns the plan for typ, or nil when typ has a custom type
+// func or isn't a string, bool or number.
+func planScalar(typ reflect.Type, custom bool) *scalarPlan {
+
+	if custom || int(typ.Kind()) >= len(scalarPlans) {
+		return nil
+	}
+
+	return scalarPlans[typ.Kind()]
+}
+
+func decodeString(p unsafe.Pointer, s string) bool {
+	*(*string)(p) = s
+	return true
+}
+
+func encodeString(p unsafe.Pointer) string {
+	return *(*string)(p)
+}
+
+func decodeBool(p unsafe.Pointer, s string) bool {
+
+	b, err := parseBool(s)
+	if err != nil {
+		return false
+	}
+
+	*(*bool)(p) = b
+
+	return true
+}
+
+func encodeBool(p unsafe.Pointer) string {
+	return strconv.FormatBool(*(*bool)(p))
+}
+
+func decodeInt[T int | int8 | int16 | int32 | int64](p unsafe.Pointer, s string) bool {
+
+	var zero T
+
+	i, err := strconv.ParseInt(s, 10, int(unsafe.Sizeof(zero))*8)
+	if err != nil {
+		return false
+	}
+
+	*(*T)(p) = T(i)
+
+	return true
+}
+
+func encodeInt[T int | int8 | int16 | int32 | int64](p unsafe.Pointer) string {
+	return strconv.FormatInt(int64(*(*T)(p)), 10)
+}
+
+func decodeUint[T uint | uint8 | uint16 | uint32 | uint64](p unsafe.Pointer, s string) bool {
+
+	var zero T
+
+	u, err := strconv.ParseUint(s, 10, int(unsafe.Sizeof(zero))*8)
+	if err != nil {
+		return false
+	}
+
+	*(*T)(p) = T(u)
+
+	return true
+}
+
+func encodeUint[T uint | uint8 | uint16 | uint32 | uint64](p unsafe.Pointer) string {
+	return strconv.FormatUint(uint64(*(*T)(p)), 10)
+}
+
+func decodeFloat[T float32 | float64](p unsafe.Pointer, s string) bool {
+
+	var zero T
+
+	f, err := strconv.ParseFloat(s, int(unsafe.Sizeof(zero))*8)
+	if err != nil {
+		return false
+	}
+
+	*(*T)(p) = T(f)
+
+	return true
+}
+
+func encodeFloat[T float32 | float64](p unsafe.Pointer) string {
+
+	var zero T
+
+	return strconv.FormatFloat(float64(*(*T)(p)), 'f', -1, int(unsafe.Sizeof(zero))*8)
+}
@@ -1,8 +1,13 @@
 package form
 
 import (
+	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // ExtractType gets the actual underlying type of field value.
@@ -44,3 +49,238 @@ func parseBool(str string) (bool, error) {
 	// to ensure compatibility with std library and beyond.
 	return false, &strconv.NumError{Func: "ParseBool", Num: str, Err: strconv.ErrSyntax}
 }
+
+// foldValues returns a copy of values with the field name portion
+// of each key folded; values of keys that fold to the same name are combined
+// in the sorted order of the keys, so the result doesn't depend on map order.
+func foldValues(values url.Values, fold CaseFolding) url.Values {
+
+	folded := make(url.Values, len(values))
+
+	keys := make([]string, 0, len(values))
+
+	for k := range values {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var fk string
+
+	for _, k := range keys {
+
+		v := values[k]
+		fk = foldName(k, fold)
+
+		if arr, ok := folded[fk]; ok {
+			folded[fk] = append(arr[:len(arr):len(arr)], v...)
+			continue
+		}
+
+		folded[fk] = v
+	}
+
+	return folded
+}
+
+// foldName folds the field name portions of s, leaving the contents of
+// any brackets eg. map keys and array indexes, untouched.
+func foldName(s string, fold CaseFolding) string {
+
+	buff := make([]byte, 0, len(s))
+
+	var insideBracket bool
+	var r rune
+	var size int
+
+	for i := 0; i < len(s); i += size {
+
+		switch c := s[i]; {
+		case c == '[':
+			insideBracket = true
+		case c == ']':
+			insideBracket = false
+		case insideBracket:
+		case c < utf8.RuneSelf:
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+
+			buff = append(buff, c)
+			size = 1
+			continue
+
+		case fold == FoldUnicode:
+
+			if r, size = utf8.DecodeRuneInString(s[i:]); r != utf8.RuneError {
+				buff = utf8.AppendRune(buff, foldRune(r))
+				continue
+			}
+		}
+
+		buff = append(buff, s[i])
+		size = 1
+	}
+
+	return string(buff)
+}
+
+// foldRune returns the canonical rune, within r's case folding orbit,
+// that all members of the orbit fold to.
+func foldRune(r rune) rune {
+
+	min := r
+
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+
+	return unicode.ToLower(min)
+}
+
+// isSQLNull reports whether typ is one of the database/sql Null types
+// eg. sql.NullString or sql.Null[T], which hold their value in the first
+// field followed by Valid.
+func isSQLNull(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && typ.PkgPath() == "database/sql" && typ.NumField() == 2 && typ.Field(1).Name == "Valid"
+}
+
+// isEmptyValue reports whether v is empty as defined by the omitempty
+// tag option, the same as encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// zeroField sets v to its zero value; an unexported embedded struct, or the
+// struct it points to, can't be set so only its exported fields are zeroed.
+func zeroField(v reflect.Value) {
+
+	if v.CanSet() {
+		v.Set(reflect.Zero(v.Type()))
+		return
+	}
+
+	if v.Kind() == reflect.Ptr {
+
+		if v.IsNil() {
+			return
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		zeroField(v.Field(i))
+	}
+}
+
+// addr returns a pointer to v, or to a copy of it when v isn't addressable
+// eg. a field of a struct passed by value.
+func addr(v reflect.Value) reflect.Value {
+
+	if v.CanAddr() {
+		return v.Addr()
+	}
+
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+
+	return p
+}
+
+// appendEscaped appends s to buff escaping any backslash or delim
+// with a backslash.
+func appendEscaped(buff []byte, s string, delim string) []byte {
+
+	for i := 0; i < len(s); i++ {
+
+		if s[i] == '\\' || strings.HasPrefix(s[i:], delim) {
+			buff = append(buff, '\\')
+		}
+
+		buff = append(buff, s[i])
+	}
+
+	return buff
+}
+
+// splitDelimited splits each of values on delim, skipping empty values, and
+// removes the backslash escaping any delimiter or backslash within a part.
+func splitDelimited(values []string, delim string, trim bool) []string {
+
+	parts := make([]string, 0, len(values)*4)
+	buff := make([]byte, 0, 64)
+
+	var s string
+
+	for _, v := range values {
+
+		if len(v) == 0 {
+			continue
+		}
+
+		buff = buff[:0]
+
+		for i := 0; i < len(v); i++ {
+
+			if v[i] == '\\' && i+1 < len(v) {
+
+				if v[i+1] == '\\' {
+					buff = append(buff, '\\')
+					i++
+					continue
+				}
+
+				if strings.HasPrefix(v[i+1:], delim) {
+					buff = append(buff, delim...)
+					i += len(delim)
+					continue
+				}
+			}
+
+			if strings.HasPrefix(v[i:], delim) {
+
+				if s = string(buff); trim {
+					s = strings.TrimSpace(s)
+				}
+
+				parts = append(parts, s)
+				buff = buff[:0]
+				i += len(delim) - 1
+				continue
+			}
+
+			buff = append(buff, v[i])
+		}
+
+		if s = string(buff); trim {
+			s = strings.TrimSpace(s)
+		}
+
+		parts = append(parts, s)
+	}
+
+	return parts
+}